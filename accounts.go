@@ -0,0 +1,312 @@
+package dvcscraper
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-rod/rod"
+)
+
+const defaultSessionDir = ".dvcscraper-sessions"
+
+// Account is a DVC member account managed by an AccountManager
+type Account struct {
+	Name string
+
+	// Credentials supplies the account's login; Email and Password are used
+	// when it's nil
+	Credentials CredentialProvider
+	Email       string
+	Password    string
+
+	// SessionStore overrides the account's file in the manager's SessionDir
+	SessionStore SessionStore
+
+	// OTPProvider overrides the manager's OTPProvider for this account
+	OTPProvider OTPProvider
+}
+
+// AccountManagerOptions configure an AccountManager
+type AccountManagerOptions struct {
+	// ScraperOptions are shared by every account. Credentials, Email,
+	// Password and SessionStore are ignored in favor of each Account's own.
+	ScraperOptions ScraperOptions
+
+	// SessionDir holds one session file per account. Defaults to
+	// .dvcscraper-sessions in the working directory.
+	SessionDir string
+
+	// ProfileDir, when set, gives every account its own browser with a
+	// persistent profile in a subdirectory named after the account instead
+	// of a context in one shared browser. See ScraperOptions.ProfileDir.
+	ProfileDir string
+}
+
+// AccountManager keeps several named accounts and hands out a Scraper per
+// account.
+//
+// All accounts share one browser process, but each gets its own browser
+// context so cookies never mix between accounts. With a ProfileDir each
+// account runs its own browser instead.
+type AccountManager struct {
+	opts AccountManagerOptions
+
+	mu       sync.Mutex
+	browser  *rod.Browser
+	accounts map[string]*managedAccount
+}
+
+type managedAccount struct {
+	account Account
+	scraper *Scraper
+}
+
+// AccountErrors collects per-account failures from AccountManager.Each
+type AccountErrors map[string]error
+
+func (a AccountErrors) Error() string {
+	names := make([]string, 0, len(a))
+	for name := range a {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", name, a[name].Error()))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// NewAccountManager returns an AccountManager with no accounts
+func NewAccountManager(opts AccountManagerOptions) *AccountManager {
+	if opts.SessionDir == "" {
+		opts.SessionDir = defaultSessionDir
+	}
+
+	return &AccountManager{
+		opts:     opts,
+		accounts: map[string]*managedAccount{},
+	}
+}
+
+// Add registers an account. Its Scraper is started on first use.
+func (m *AccountManager) Add(account Account) error {
+	if account.Name == "" {
+		return errors.New("account name is required")
+	}
+
+	if account.SessionStore == nil {
+		store, err := NewDirSessionStore(m.opts.SessionDir, account.Name)
+		if err != nil {
+			err = fmt.Errorf("failed to create session store for '%s': %w", account.Name, err)
+			return err
+		}
+		account.SessionStore = store
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.accounts[account.Name]; ok {
+		return fmt.Errorf("account '%s' already added", account.Name)
+	}
+
+	m.accounts[account.Name] = &managedAccount{account: account}
+	return nil
+}
+
+// Remove closes the account's Scraper, saving its session, and forgets it
+func (m *AccountManager) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	managed, ok := m.accounts[name]
+	if !ok {
+		return fmt.Errorf("unknown account '%s'", name)
+	}
+	delete(m.accounts, name)
+
+	if managed.scraper == nil {
+		return nil
+	}
+
+	return managed.scraper.Close()
+}
+
+// Accounts returns the registered accounts sorted by name
+func (m *AccountManager) Accounts() []Account {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	accounts := make([]Account, 0, len(m.accounts))
+	for _, managed := range m.accounts {
+		accounts = append(accounts, managed.account)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Name < accounts[j].Name
+	})
+
+	return accounts
+}
+
+// Scraper returns the named account's Scraper, starting it if needed
+func (m *AccountManager) Scraper(name string) (*Scraper, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	managed, ok := m.accounts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown account '%s'", name)
+	}
+
+	if managed.scraper != nil {
+		return managed.scraper, nil
+	}
+
+	opts := m.accountOptions(managed.account)
+
+	if m.opts.ProfileDir != "" {
+		profile, err := profilePath(m.opts.ProfileDir, name)
+		if err != nil {
+			return nil, err
+		}
+		opts.ProfileDir = profile
+
+		scraper, err := New(opts)
+		if err != nil {
+			// a Scraper that failed to start closes its browser and
+			// releases the profile without saving over the account's
+			// session
+			scraper.Close()
+			err = fmt.Errorf("failed to start scraper for '%s': %w", name, err)
+			return nil, err
+		}
+
+		managed.scraper = &scraper
+		return managed.scraper, nil
+	}
+
+	if m.browser == nil {
+		browser, err := connectBrowser(m.opts.ScraperOptions)
+		if err != nil {
+			err = fmt.Errorf("failed to start shared browser: %w", err)
+			return nil, err
+		}
+		m.browser = browser
+	}
+
+	browser, err := m.browser.Incognito()
+	if err != nil {
+		err = fmt.Errorf("failed to create browser context for '%s': %w", name, err)
+		return nil, err
+	}
+
+	scraper, err := newWithBrowser(browser, opts)
+	if err != nil {
+		browser.Close()
+		err = fmt.Errorf("failed to start scraper for '%s': %w", name, err)
+		return nil, err
+	}
+	// the account's context is ours to close even in a remote browser
+	scraper.keepBrowser = false
+
+	managed.scraper = &scraper
+	return managed.scraper, nil
+}
+
+// accountOptions returns the shared ScraperOptions with the account's own
+// login, session and logger
+func (m *AccountManager) accountOptions(account Account) ScraperOptions {
+	opts := m.opts.ScraperOptions
+	opts.Credentials = account.Credentials
+	opts.Email = account.Email
+	opts.Password = account.Password
+	opts.SessionStore = account.SessionStore
+	if account.OTPProvider != nil {
+		opts.OTPProvider = account.OTPProvider
+	}
+	opts.Logger = accountLogger(opts.Logger, account.Name)
+
+	return opts
+}
+
+// profilePath returns the account's profile directory inside dir
+func profilePath(dir, account string) (string, error) {
+	name := filepath.Base(filepath.Clean(account))
+	if name != account || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid account name for profile directory: '%s'", account)
+	}
+
+	return filepath.Join(dir, name), nil
+}
+
+// Each calls fn with every account's Scraper in name order. A failure for one
+// account doesn't stop the others; all failures are returned as AccountErrors.
+func (m *AccountManager) Each(fn func(Account, *Scraper) error) error {
+	errs := AccountErrors{}
+	for _, account := range m.Accounts() {
+		scraper, err := m.Scraper(account.Name)
+		if err == nil {
+			err = fn(account, scraper)
+		}
+		if err != nil {
+			errs[account.Name] = err
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Close closes every started Scraper, saving their sessions, and then the
+// shared browser
+func (m *AccountManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	errs := AccountErrors{}
+	for name, managed := range m.accounts {
+		if managed.scraper == nil {
+			continue
+		}
+
+		err := managed.scraper.Close()
+		if err != nil {
+			errs[name] = err
+		}
+		managed.scraper = nil
+	}
+
+	if m.browser != nil && m.opts.ScraperOptions.Browser.remoteURL() != "" {
+		// leave the remote browser running for its other users
+		m.browser = nil
+	} else if m.browser != nil {
+		err := m.browser.Close()
+		if err != nil {
+			err = fmt.Errorf("failed to close shared browser: %w", err)
+			return err
+		}
+		m.browser = nil
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func accountLogger(base *log.Logger, name string) *log.Logger {
+	if base == nil {
+		base = log.Default()
+	}
+
+	return log.New(base.Writer(), base.Prefix()+"["+name+"] ", base.Flags())
+}
@@ -0,0 +1,195 @@
+package dvcscraper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAccountManagerAdd(t *testing.T) {
+	dir := t.TempDir()
+	m := NewAccountManager(AccountManagerOptions{SessionDir: dir})
+
+	tests := []struct {
+		name    string
+		account Account
+		wantErr string
+	}{
+		{name: "added", account: Account{Name: "alice"}},
+		{name: "second", account: Account{Name: "bob"}},
+		{name: "duplicate", account: Account{Name: "alice"}, wantErr: "already added"},
+		{name: "no name", account: Account{}, wantErr: "name is required"},
+		{name: "path name", account: Account{Name: "../carol"}, wantErr: "invalid account name"},
+		{name: "own store allows any name", account: Account{Name: "dave/2", SessionStore: NewMemorySessionStore()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.Add(tt.account)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	names := []string{}
+	for _, account := range m.Accounts() {
+		names = append(names, account.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"alice", "bob", "dave/2"}) {
+		t.Fatalf("accounts %v", names)
+	}
+
+	alice := m.Accounts()[0]
+	store, ok := alice.SessionStore.(*FileSessionStore)
+	if !ok || store.Path != filepath.Join(dir, "alice.json") {
+		t.Fatalf("alice's session store is %#v", alice.SessionStore)
+	}
+}
+
+func TestAccountManagerRemove(t *testing.T) {
+	m := NewAccountManager(AccountManagerOptions{SessionDir: t.TempDir()})
+	_ = m.Add(Account{Name: "alice"})
+
+	err := m.Remove("alice")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(m.Accounts()) != 0 {
+		t.Fatalf("alice still registered")
+	}
+
+	err = m.Remove("alice")
+	if err == nil {
+		t.Fatal("expected error removing an unknown account")
+	}
+
+	err = m.Add(Account{Name: "alice"})
+	if err != nil {
+		t.Fatalf("failed to add again after removal: %v", err)
+	}
+}
+
+func TestAccountManagerScraperUnknown(t *testing.T) {
+	m := NewAccountManager(AccountManagerOptions{SessionDir: t.TempDir()})
+
+	_, err := m.Scraper("nobody")
+	if err == nil || !strings.Contains(err.Error(), "unknown account") {
+		t.Fatalf("expected unknown account error, got %v", err)
+	}
+}
+
+func TestAccountOptions(t *testing.T) {
+	shared := StaticOTP("111111")
+	own := StaticOTP("222222")
+	store := NewMemorySessionStore()
+	base := log.New(&bytes.Buffer{}, "svc ", 0)
+
+	m := NewAccountManager(AccountManagerOptions{
+		ScraperOptions: ScraperOptions{
+			Email:        "shared@example.com",
+			Password:     "shared",
+			OTPProvider:  shared,
+			SessionStore: NewMemorySessionStore(),
+			Logger:       base,
+			PagePoolSize: 2,
+		},
+	})
+
+	tests := []struct {
+		name    string
+		account Account
+		wantOTP string
+	}{
+		{name: "shared otp", account: Account{Name: "alice", Email: "alice@example.com", Password: "a", SessionStore: store}, wantOTP: "111111"},
+		{name: "own otp", account: Account{Name: "bob", Email: "bob@example.com", Password: "b", SessionStore: store, OTPProvider: own}, wantOTP: "222222"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := m.accountOptions(tt.account)
+			if opts.Email != tt.account.Email || opts.Password != tt.account.Password {
+				t.Fatalf("login %s/%s, want the account's", opts.Email, opts.Password)
+			}
+			if opts.SessionStore != SessionStore(store) {
+				t.Fatalf("session store not the account's")
+			}
+			code, _ := opts.OTPProvider.OTP(context.Background(), tt.account.Email)
+			if code != tt.wantOTP {
+				t.Fatalf("otp provider gave %s, want %s", code, tt.wantOTP)
+			}
+			if opts.PagePoolSize != 2 {
+				t.Fatalf("shared options lost: %+v", opts)
+			}
+			if opts.Logger.Prefix() != "svc ["+tt.account.Name+"] " {
+				t.Fatalf("logger prefix %q", opts.Logger.Prefix())
+			}
+		})
+	}
+
+	if m.opts.ScraperOptions.Logger != base {
+		t.Fatal("account options changed the shared options")
+	}
+}
+
+func TestProfilePath(t *testing.T) {
+	tests := []struct {
+		account string
+		want    string
+		wantErr bool
+	}{
+		{account: "alice", want: filepath.Join("profiles", "alice")},
+		{account: "", wantErr: true},
+		{account: "..", wantErr: true},
+		{account: "a/b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := profilePath("profiles", tt.account)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Fatalf("profilePath(%q) = %q, %v", tt.account, got, err)
+		}
+	}
+}
+
+func TestAccountManagerEachCollectsErrors(t *testing.T) {
+	// names that can't be profile directories fail before any browser starts
+	m := NewAccountManager(AccountManagerOptions{ProfileDir: t.TempDir()})
+	_ = m.Add(Account{Name: "a/b", SessionStore: NewMemorySessionStore()})
+	_ = m.Add(Account{Name: "..", SessionStore: NewMemorySessionStore()})
+
+	called := 0
+	err := m.Each(func(Account, *Scraper) error {
+		called++
+		return nil
+	})
+
+	var errs AccountErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("expected two AccountErrors, got %v", err)
+	}
+	if called != 0 {
+		t.Fatalf("fn called %d times for accounts that failed to start", called)
+	}
+	if !strings.HasPrefix(errs.Error(), "..: ") || !strings.Contains(errs.Error(), "; a/b: ") {
+		t.Fatalf("errors not sorted by account: %s", errs.Error())
+	}
+}
+
+func TestAccountManagerCloseWithoutScrapers(t *testing.T) {
+	m := NewAccountManager(AccountManagerOptions{SessionDir: t.TempDir()})
+	_ = m.Add(Account{Name: "alice"})
+
+	err := m.Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -21,17 +21,65 @@ const (
 	signInPasswordSelector = ".field-password input"
 	signInSubmitSelector   = ".workflow-login .btn-submit"
 	signInErrorSelector    = ".banner.login.message-error.message.state-active"
+
+	signInOTPSelector       = ".workflow-otp .field-otp-code input"
+	signInOTPSubmitSelector = ".workflow-otp .btn-submit"
+	signInOTPErrorSelector  = ".workflow-otp .message-error.state-active"
+
+	defaultOTPTimeout   = 5 * time.Minute
+	presencePollTimeout = 500 * time.Millisecond
 )
 
 // Login authenticates to gain access to protected parts of the DVC site
 func (s *Scraper) Login() error {
-	page, err := s.getPage()
+	return s.LoginContext(context.Background())
+}
+
+// LoginContext is Login bounded by ctx, including any wait for credentials or
+// a one-time passcode
+func (s *Scraper) LoginContext(ctx context.Context) error {
+	page, err := s.pages.get(ctx)
 	if err != nil {
-		err = fmt.Errorf("failed to get bypass page: %w", err)
+		err = fmt.Errorf("failed to get page for auth: %w", err)
 		return err
 	}
+
+	err = s.loginOn(ctx, page)
+	s.pages.put(page, err)
+	return err
+}
+
+// loginOn logs in using page. When another login finishes while this one
+// waits its turn, the session is already fresh and nothing more is done.
+func (s *Scraper) loginOn(ctx context.Context, page *rod.Page) error {
+	requested := time.Now()
+
+	s.login.mu.Lock()
+	defer s.login.mu.Unlock()
+	if s.login.last.After(requested) {
+		s.logger.Println("session refreshed by a concurrent login")
+		return nil
+	}
+
+	start := time.Now()
+	err := s.signIn(ctx, page)
+	s.metrics.Login(err)
+	s.observe(endpointLogin, start, err)
+	if err == nil {
+		s.login.last = time.Now()
+	}
+	return err
+}
+
+func (s *Scraper) signIn(ctx context.Context, page *rod.Page) error {
+	page = page.Context(ctx)
 	s.logger.Println("got page for auth")
 
+	err := s.waitRateLimit(ctx, EndpointNavigation)
+	if err != nil {
+		return err
+	}
+
 	err = page.Navigate(signinURL)
 	if err != nil {
 		err = fmt.Errorf("failed to visit sign in page: %w", err)
@@ -39,9 +87,10 @@ func (s *Scraper) Login() error {
 	}
 	s.logger.Println("navigated for auth")
 
+	width, height := s.browserOpts.windowSize()
 	err = page.SetViewport(&proto.EmulationSetDeviceMetricsOverride{
-		Width:  2560,
-		Height: 1400,
+		Width:  width,
+		Height: height,
 	})
 	if err != nil {
 		err = fmt.Errorf("failed to set viewport: %w", err)
@@ -55,14 +104,20 @@ func (s *Scraper) Login() error {
 	}
 	s.logger.Println("got iframe for auth")
 
-	err = typeInput(frame, signInEmailSelector, s.email)
+	creds, err := s.credentials.Credentials(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to get credentials: %w", err)
+		return err
+	}
+
+	err = typeInput(frame, signInEmailSelector, creds.Email)
 	if err != nil {
 		err = fmt.Errorf("failed to input email address: %w", err)
 		return err
 	}
 	s.logger.Println("entered email for auth")
 
-	err = typeInput(frame, signInPasswordSelector, s.password)
+	err = typeInput(frame, signInPasswordSelector, creds.Password)
 	if err != nil {
 		err = fmt.Errorf("failed to input password: %w", err)
 		return err
@@ -78,12 +133,29 @@ func (s *Scraper) Login() error {
 	wait()
 	s.logger.Println("clicked sign in for auth")
 
+	found, err := firstPresent(signinSuccessTimeout,
+		presence{page, dashboardCheckSelector},
+		presence{frame, signInOTPSelector},
+	)
+	if err != nil {
+		err = fmt.Errorf("failed to check for passcode challenge: %w", err)
+		return err
+	}
+	if found == 1 {
+		s.logger.Println("passcode requested for auth")
+		err = s.submitOTP(ctx, page, frame, creds.Email)
+		if err != nil {
+			return err
+		}
+		s.logger.Println("entered passcode for auth")
+	}
+
 	s.logger.Println("waiting for sign in results")
 	err = rod.Try(func() {
 		s.logger.Println("checking for", dashboardCheckSelector)
 		page.Timeout(signinSuccessTimeout).MustElement(dashboardCheckSelector)
 	})
-	if errors.Is(err, context.DeadlineExceeded) {
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
 		filename := fmt.Sprintf("login-error-%s.png", time.Now().Format(time.RFC3339))
 		page.MustScreenshotFullPage(filename)
 		lErr := loginError{}
@@ -109,10 +181,122 @@ func (s *Scraper) Login() error {
 	return err
 }
 
+func (s *Scraper) submitOTP(ctx context.Context, page, frame *rod.Page, email string) error {
+	if s.otp == nil {
+		return loginError{
+			msg:             "login requires a one-time passcode but no OTPProvider is configured",
+			certainlyFailed: true,
+		}
+	}
+
+	otpCtx, cancel := context.WithTimeout(ctx, s.otpTimeout)
+	defer cancel()
+
+	code, err := s.otp.OTP(otpCtx, email)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return loginError{
+			msg:         fmt.Sprintf("timed out after %s waiting for one-time passcode", s.otpTimeout),
+			otpTimedOut: true,
+		}
+	} else if ctx.Err() != nil {
+		return ctx.Err()
+	} else if err != nil {
+		return loginError{msg: fmt.Sprintf("failed to get one-time passcode: %s", err.Error())}
+	}
+
+	err = typeInput(frame, signInOTPSelector, code)
+	if err != nil {
+		err = fmt.Errorf("failed to input passcode: %w", err)
+		return err
+	}
+
+	wait := waitNavigation(page)
+	err = s.click(frame, signInOTPSubmitSelector)
+	if err != nil {
+		err = fmt.Errorf("failed to click to submit passcode: %w", err)
+		return err
+	}
+	wait()
+
+	found, err := firstPresent(signinSuccessTimeout,
+		presence{page, dashboardCheckSelector},
+		presence{frame, signInOTPErrorSelector},
+	)
+	if err != nil {
+		err = fmt.Errorf("failed to check passcode result: %w", err)
+		return err
+	}
+	if found == 1 {
+		text, err := textOfElement(frame, signInOTPErrorSelector)
+		if err != nil {
+			text = err.Error()
+		}
+		return loginError{
+			msg:             fmt.Sprintf("one-time passcode rejected: '%s'", text),
+			certainlyFailed: true,
+			otpRejected:     true,
+		}
+	}
+
+	return nil
+}
+
+type presence struct {
+	page     *rod.Page
+	selector string
+}
+
+// firstPresent polls the targets until one of their selectors is found and
+// returns its index, or -1 if none show up before the timeout
+func firstPresent(timeout time.Duration, targets ...presence) (int, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		for i, target := range targets {
+			has, _, err := target.page.Has(target.selector)
+			if err != nil {
+				return -1, err
+			}
+			if has {
+				return i, nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return -1, nil
+		}
+		time.Sleep(presencePollTimeout)
+	}
+}
+
 type loginError struct {
 	msg             string
 	certainlyFailed bool
+	otpTimedOut     bool
+	otpRejected     bool
 }
 
 func (l loginError) Error() string         { return l.msg }
 func (l loginError) CertainlyFailed() bool { return l.certainlyFailed }
+func (l loginError) OTPTimedOut() bool     { return l.otpTimedOut }
+func (l loginError) OTPRejected() bool     { return l.otpRejected }
+func (l loginError) Is(target error) bool {
+	return target == ErrLoginRejected && l.certainlyFailed
+}
+
+// IsOTPTimeout reports whether err is a login that gave up waiting for the
+// one-time passcode
+func IsOTPTimeout(err error) bool {
+	var timedOut interface {
+		OTPTimedOut() bool
+	}
+	return errors.As(err, &timedOut) && timedOut.OTPTimedOut()
+}
+
+// IsOTPRejected reports whether err is a login where the site refused the
+// one-time passcode
+func IsOTPRejected(err error) bool {
+	var rejected interface {
+		OTPRejected() bool
+	}
+	return errors.As(err, &rejected) && rejected.OTPRejected()
+}
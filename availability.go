@@ -1,11 +1,18 @@
 package dvcscraper
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-rod/rod"
+	"github.com/lineleader/dvc-scraper/catalog"
 )
 
 const (
@@ -21,23 +28,55 @@ const (
 
 	uiDateFormat = "01/02/2006"
 	dateFormat   = "2006-01-02"
+
+	defaultAPITimeout = 5 * time.Second
+	abortFetchTimeout = time.Second
 )
 
 // AvailabilityOptions configure an availability request
 type AvailabilityOptions struct {
-	Resort   string    `json:"resort"`
-	RoomType string    `json:"roomType"`
-	Date     time.Time `json:"startDate"`
+	Resort   catalog.ResortCode   `json:"resort"`
+	RoomType catalog.RoomTypeCode `json:"roomType"`
+	Date     time.Time            `json:"startDate"`
+}
+
+// Validate checks a resort and room type were given. Codes that aren't in
+// the catalog are allowed; the catalog is maintained by hand and may not know
+// every code the booking API accepts.
+func (o AvailabilityOptions) Validate() error {
+	return validateCodes(o.Resort, o.RoomType)
+}
+
+func validateCodes(resort catalog.ResortCode, roomType catalog.RoomTypeCode) error {
+	if strings.TrimSpace(string(resort)) == "" {
+		return errors.New("resort code is required")
+	}
+	if strings.TrimSpace(string(roomType)) == "" {
+		return errors.New("room type code is required")
+	}
+	return nil
 }
 
 type AvailabilityResults struct {
-	ResortCode   string `json:"resortCode"`
-	RoomCode     string `json:"roomCode"`
-	Availability []struct {
-		Date   string `json:"date"`
-		Rooms  int    `json:"rooms"`
-		Points int    `json:"points"`
-	} `json:"availability"`
+	ResortCode   catalog.ResortCode   `json:"resortCode"`
+	RoomCode     catalog.RoomTypeCode `json:"roomCode"`
+	Availability []DayAvailability    `json:"availability"`
+}
+
+// DayAvailability is the availability of a room type for one night
+type DayAvailability struct {
+	Date   string `json:"date"`
+	Rooms  int    `json:"rooms"`
+	Points int    `json:"points"`
+}
+
+// Day returns the night's date as YYYY-MM-DD, without any time the API
+// included
+func (d DayAvailability) Day() string {
+	if len(d.Date) > len(dateFormat) {
+		return d.Date[:len(dateFormat)]
+	}
+	return d.Date
 }
 
 type CalendarRequestBody struct {
@@ -50,13 +89,32 @@ type CalendarRequestBody struct {
 	IsModify   bool      `json:"isModify"`
 }
 
+// AvailabilityHandle requests availability from a page that has been
+// prepared by NewAvailabilityHandle. The handle owns its page until Close is
+// called. Use a handle from one goroutine at a time; open a handle per
+// goroutine to request availability concurrently.
 type AvailabilityHandle struct {
-	page *rod.Page
+	page    *rod.Page
+	scraper *Scraper
+	// pageErr is the last failure that may have left page unusable; Close
+	// discards the page rather than pooling it when it's set
+	pageErr error
+
+	closeOnce *sync.Once
 }
 
+// NewAvailabilityHandle opens the booking page and runs a search so that
+// availability can be requested from the booking API. The handle holds a
+// page from the Scraper's pool; Close it when done.
 func (s *Scraper) NewAvailabilityHandle() (*AvailabilityHandle, error) {
-	handle := AvailabilityHandle{}
-	page, err := s.getPage()
+	return s.NewAvailabilityHandleContext(context.Background())
+}
+
+// NewAvailabilityHandleContext is NewAvailabilityHandle bounded by ctx. ctx
+// does not limit the lifetime of the returned handle.
+func (s *Scraper) NewAvailabilityHandleContext(ctx context.Context) (*AvailabilityHandle, error) {
+	handle := AvailabilityHandle{scraper: s, closeOnce: &sync.Once{}}
+	page, err := s.pages.get(ctx)
 	if err != nil {
 		err = fmt.Errorf("failed to get page: %w", err)
 		return &handle, err
@@ -64,11 +122,29 @@ func (s *Scraper) NewAvailabilityHandle() (*AvailabilityHandle, error) {
 	s.logger.Println("got page for avail")
 
 	handle.page = page
+	err = handle.open(ctx)
+	if err != nil {
+		handle.pageErr = err
+		handle.Close()
+		return &handle, err
+	}
+
+	return &handle, nil
+}
+
+// open runs the booking page search on the handle's page
+func (h *AvailabilityHandle) open(ctx context.Context) error {
+	s := h.scraper
+	page := h.page.Context(ctx)
 
-	err = s.AuthenticatedNavigate(bookingPage, closeTermsSelector)
+	start := time.Now()
+	err := s.withRetry(ctx, endpointNavigation, "navigation to "+bookingPage, s.reauthOn(h.page), func() error {
+		return s.authenticatedNavigate(ctx, h.page, bookingPage, closeTermsSelector)
+	})
+	s.observe(endpointNavigation, start, err)
 	if err != nil {
 		err = fmt.Errorf("failed to navigate to booking page: %w", err)
-		return &handle, err
+		return err
 	}
 	s.logger.Println("navigated to booking page for avail")
 
@@ -85,7 +161,7 @@ func (s *Scraper) NewAvailabilityHandle() (*AvailabilityHandle, error) {
 	err = s.click(page, startSelector)
 	if err != nil {
 		err = fmt.Errorf("failed to click start date (%s): %w", startDate, err)
-		return &handle, err
+		return err
 	}
 	s.logger.Println("Clicked start date")
 
@@ -93,46 +169,124 @@ func (s *Scraper) NewAvailabilityHandle() (*AvailabilityHandle, error) {
 	err = s.click(page, endDateSelector)
 	if err != nil {
 		err = fmt.Errorf("failed to click end date (%s): %w", endDate, err)
-		return &handle, err
+		return err
 	}
 	s.logger.Println("Clicked end date")
 
 	err = s.click(page, deluxeStudioButtonSelector)
 	if err != nil {
 		err = fmt.Errorf("failed to click deluxe studio button: %w", err)
-		return &handle, err
+		return err
 	}
 	s.logger.Println("Clicked studio button")
 
 	err = s.click(page, checkAvailabilityButtonSelector)
 	if err != nil {
 		err = fmt.Errorf("failed to click check availability button: %w", err)
-		return &handle, err
+		return err
 	}
 	s.logger.Println("Clicked check availability button")
 
 	err = page.WaitLoad()
 	if err != nil {
 		err = fmt.Errorf("failed to wait for search page to load: %w", err)
-		return &handle, err
+		return err
 	}
 	s.logger.Println("Waited loading")
 
-	return &handle, nil
+	return nil
 }
 
+// Close returns the handle's page to the Scraper's pool, or closes it if a
+// request left it unusable. The handle can't be used afterwards. Calling
+// Close more than once is harmless.
+func (h *AvailabilityHandle) Close() error {
+	if h.scraper == nil || h.page == nil || h.closeOnce == nil {
+		return nil
+	}
+
+	h.closeOnce.Do(func() {
+		h.scraper.pages.put(h.page, h.pageErr)
+	})
+	return nil
+}
+
+// GetAvailability returns availability for the month of opts.Date
 func (h *AvailabilityHandle) GetAvailability(opts AvailabilityOptions) (AvailabilityResults, error) {
-	results := AvailabilityResults{}
-	page := h.page
+	return h.GetAvailabilityContext(context.Background(), opts)
+}
+
+// GetAvailabilityContext is GetAvailability bounded by ctx. The request made
+// from the page is aborted when ctx is done.
+func (h *AvailabilityHandle) GetAvailabilityContext(ctx context.Context, opts AvailabilityOptions) (AvailabilityResults, error) {
+	err := opts.Validate()
+	if err != nil {
+		err = fmt.Errorf("invalid availability options: %w", err)
+		return AvailabilityResults{}, err
+	}
 
+	resort, roomType := h.canonicalCodes(opts.Resort, opts.RoomType)
 	start, end := startEnd(opts.Date)
 	body := CalendarRequestBody{
-		Resort:    opts.Resort,
-		RoomType:  opts.RoomType,
+		Resort:    string(resort),
+		RoomType:  string(roomType),
 		StartDate: start.Format(dateFormat),
 		EndDate:   end.Format(dateFormat),
 	}
 
+	results, err := h.calendar(ctx, body)
+	if err == nil && h.scraper != nil {
+		h.scraper.observeNights(results)
+	}
+	return results, err
+}
+
+// canonicalCodes spells the codes as the catalog does, warning about codes it
+// doesn't know, which are requested as given
+func (h *AvailabilityHandle) canonicalCodes(resort catalog.ResortCode, roomType catalog.RoomTypeCode) (catalog.ResortCode, catalog.RoomTypeCode) {
+	resort, roomType, err := catalog.Canonical(resort, roomType)
+	if err != nil && h.scraper != nil {
+		h.scraper.logger.Printf("warning: %s; requesting it anyway", err.Error())
+	}
+	return resort, roomType
+}
+
+// calendar requests body from the booking API under the Scraper's retry policy
+func (h *AvailabilityHandle) calendar(ctx context.Context, body CalendarRequestBody) (AvailabilityResults, error) {
+	if h.scraper == nil {
+		return h.getAvailability(ctx, body)
+	}
+
+	var results AvailabilityResults
+	start := time.Now()
+	err := h.scraper.withRetry(ctx, endpointAvailability, "availability for "+body.Resort+" "+body.RoomType, h.reauth, func() error {
+		var err error
+		results, err = h.getAvailability(ctx, body)
+		return err
+	})
+	h.scraper.observe(endpointAvailability, start, err)
+	return results, err
+}
+
+// reauth logs in again and returns the page to the booking page so that
+// availability requests can resume
+func (h *AvailabilityHandle) reauth(ctx context.Context) error {
+	h.pageErr = h.scraper.authenticatedNavigate(ctx, h.page, bookingPage, closeTermsSelector)
+	return h.pageErr
+}
+
+func (h *AvailabilityHandle) getAvailability(ctx context.Context, body CalendarRequestBody) (AvailabilityResults, error) {
+	results := AvailabilityResults{}
+	page := h.page.Context(ctx)
+
+	if h.scraper != nil {
+		err := h.scraper.waitRateLimit(ctx, EndpointAPI)
+		if err != nil {
+			return results, err
+		}
+	}
+
+	fetchID := nextFetchID()
 	obj, err := page.Evaluate(&rod.EvalOptions{
 		AwaitPromise: true,
 		ByValue:      true,
@@ -142,22 +296,97 @@ func (h *AvailabilityHandle) GetAvailability(opts AvailabilityOptions) (Availabi
 		JSArgs: []interface{}{
 			calendarURL,
 			body,
+			apiTimeout(ctx).Milliseconds(),
+			fetchID,
 		},
 	})
+	h.pageErr = err
 	if err != nil {
+		if ctx.Err() != nil {
+			abortFetch(h.page, fetchID)
+		}
 		err = fmt.Errorf("failed to Evaluate: %w", err)
 		return results, err
 	}
 
-	err = json.Unmarshal([]byte(obj.Value.JSON("", "")), &results)
+	resp := fetchResponse{}
+	err = json.Unmarshal([]byte(obj.Value.JSON("", "")), &resp)
 	if err != nil {
-		err = fmt.Errorf("failed to unmarshal results: %w -- %s", err, obj.Value.JSON("", ""))
+		err = fmt.Errorf("failed to unmarshal fetch response: %w -- %s", err, obj.Value.JSON("", ""))
+		return results, err
+	}
+
+	if resp.Error != "" {
+		err = fmt.Errorf("failed to fetch availability: %s", resp.Error)
+		return results, err
+	}
+
+	if resp.Status == http.StatusTooManyRequests {
+		retryAfter := parseRetryAfter(resp.RetryAfter)
+		if h.scraper != nil {
+			h.scraper.pauseRateLimit(retryAfter)
+		}
+		return results, &APIError{URL: calendarURL, Status: resp.Status, Body: resp.Body, RetryAfter: retryAfter}
+	}
+
+	if resp.Status < 200 || resp.Status >= 300 {
+		return results, &APIError{URL: calendarURL, Status: resp.Status, Body: resp.Body}
+	}
+
+	err = json.Unmarshal([]byte(resp.Body), &results)
+	if err != nil {
+		err = fmt.Errorf("failed to unmarshal results (%s) -- %s: %w", err.Error(), resp.Body, ErrSiteChanged)
 		return results, err
 	}
 
 	return results, nil
 }
 
+// fetchResponse is what the in-page fetch scripts resolve to
+type fetchResponse struct {
+	Status     int    `json:"status"`
+	Body       string `json:"body"`
+	RetryAfter string `json:"retryAfter"`
+	Error      string `json:"error"`
+}
+
+// fetchIDs numbers in-page fetches so a cancelled one can be aborted
+var fetchIDs uint64
+
+func nextFetchID() uint64 {
+	return atomic.AddUint64(&fetchIDs, 1)
+}
+
+// abortFetch stops an in-page fetch whose caller gave up on it. Evaluate
+// returns as soon as its ctx is done, but the fetch carries on in the page
+// until aborted.
+func abortFetch(page *rod.Page, id uint64) {
+	_, _ = page.Timeout(abortFetchTimeout).Evaluate(rod.Eval(abortFetchJS, id))
+}
+
+// bookingWindowEnd is the last date the booking API will return availability
+// for when asked on now
+func bookingWindowEnd(now time.Time) time.Time {
+	return now.AddDate(0, 11, 6)
+}
+
+// apiTimeout is how long an in-page API request may take: the default or
+// whatever is left before ctx's deadline, if that's sooner
+func apiTimeout(ctx context.Context) time.Duration {
+	timeout := defaultAPITimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline)
+		if remaining < timeout {
+			timeout = remaining
+		}
+	}
+
+	if timeout < 0 {
+		timeout = 0
+	}
+	return timeout
+}
+
 func bookingDates() (string, string) {
 	initial := time.Now().AddDate(0, 7, 0)
 	loc := initial.Location()
@@ -180,27 +409,46 @@ func startEnd(in time.Time) (time.Time, time.Time) {
 	}
 
 	if endDate.Month() == time.Now().AddDate(0, 11, 0).Month() {
-		endDate = time.Now().AddDate(0, 11, 6)
+		endDate = bookingWindowEnd(time.Now())
 	}
 
 	return startDate, endDate
 }
 
-const getAvailJS = `(url, body) => {
+const getAvailJS = `(url, body, timeout, id) => {
 	const controller = new AbortController();
-	const timeoutId = setTimeout(() => controller.abort(), 5000)
+	const fetches = window.__dvcscraperFetches = window.__dvcscraperFetches || {};
+	fetches[id] = controller;
+	const timeoutId = setTimeout(() => controller.abort(), timeout)
 	return fetch(url, {
 		signal: controller.signal,
 		method: "POST",
 		headers: {
 			Accept: "application/json, text/plain, */*",
-			"Accept-Language": "en-US,en;q=0.5",
 			"Content-Type": "application/json;charset=utf-8",
 			ADRUM: "isAjax:true",
 			Pragma: "no-cache",
 			"Cache-Control": "no-cache",
 		},
 		body: JSON.stringify(body),
-	}).then(r => r.json()).catch(error => error.message)
+	}).then(r => r.text().then(text => ({
+		status: r.status,
+		body: text,
+		retryAfter: r.headers.get("Retry-After") || "",
+	}))).catch(error => ({ error: error.message || String(error) }))
+		.finally(() => {
+			clearTimeout(timeoutId);
+			delete fetches[id];
+		})
+}
+`
+
+// abortFetchJS aborts the in-page fetch started with the given id, if it's
+// still running
+const abortFetchJS = `(id) => {
+	const controller = (window.__dvcscraperFetches || {})[id];
+	if (controller) {
+		controller.abort();
+	}
 }
 `
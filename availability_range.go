@@ -0,0 +1,193 @@
+package dvcscraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lineleader/dvc-scraper/catalog"
+)
+
+// AvailabilityRangeOptions configure an availability request spanning any
+// number of months. Start and End are inclusive.
+type AvailabilityRangeOptions struct {
+	Resort   catalog.ResortCode   `json:"resort"`
+	RoomType catalog.RoomTypeCode `json:"roomType"`
+	Start    time.Time            `json:"start"`
+	End      time.Time            `json:"end"`
+}
+
+// Validate checks a resort and room type were given and the range isn't
+// backwards. As with AvailabilityOptions, codes needn't be in the catalog.
+func (o AvailabilityRangeOptions) Validate() error {
+	if o.End.Before(o.Start) {
+		return errors.New("availability range ends before it starts")
+	}
+	return validateCodes(o.Resort, o.RoomType)
+}
+
+// DateError is a date bound ParseDateRange couldn't use
+type DateError struct {
+	// Bound is "from" or "to"
+	Bound  string
+	Value  string
+	Reason string
+}
+
+func (e *DateError) Error() string {
+	return fmt.Sprintf("invalid %s '%s': %s", e.Bound, e.Value, e.Reason)
+}
+
+// ParseDateRange parses inclusive YYYY-MM-DD bounds for an availability range
+// as local midnights. An empty from means the day of now and an empty to
+// means the last day of from's month. Errors are *DateError.
+func ParseDateRange(from, to string, now time.Time) (time.Time, time.Time, error) {
+	start := startOfDay(now.In(time.Local))
+	if from != "" {
+		date, err := time.ParseInLocation(dateFormat, from, time.Local)
+		if err != nil {
+			return start, start, &DateError{Bound: "from", Value: from, Reason: "want YYYY-MM-DD"}
+		}
+		start = date
+	}
+
+	y, m, _ := start.Date()
+	end := time.Date(y, m+1, 0, 0, 0, 0, 0, start.Location())
+	if to != "" {
+		date, err := time.ParseInLocation(dateFormat, to, time.Local)
+		if err != nil {
+			return start, end, &DateError{Bound: "to", Value: to, Reason: "want YYYY-MM-DD"}
+		}
+		end = date
+	}
+
+	if end.Before(start) {
+		return start, end, &DateError{Bound: "to", Value: end.Format(dateFormat), Reason: "before from"}
+	}
+	return start, end, nil
+}
+
+// dateRange is an inclusive span of whole days
+type dateRange struct {
+	start time.Time
+	end   time.Time
+}
+
+// GetAvailabilityRange returns availability for every night from opts.Start
+// to opts.End that is inside the booking window
+func (h *AvailabilityHandle) GetAvailabilityRange(opts AvailabilityRangeOptions) (AvailabilityResults, error) {
+	return h.GetAvailabilityRangeContext(context.Background(), opts)
+}
+
+// GetAvailabilityRangeContext is GetAvailabilityRange bounded by ctx.
+//
+// The range is split into the month-sized requests the booking API accepts
+// and the nights from each are merged into one series sorted by date.
+func (h *AvailabilityHandle) GetAvailabilityRangeContext(ctx context.Context, opts AvailabilityRangeOptions) (AvailabilityResults, error) {
+	results := AvailabilityResults{
+		ResortCode:   opts.Resort,
+		RoomCode:     opts.RoomType,
+		Availability: []DayAvailability{},
+	}
+
+	err := opts.Validate()
+	if err != nil {
+		err = fmt.Errorf("invalid availability options: %w", err)
+		return results, err
+	}
+
+	resort, roomType := h.canonicalCodes(opts.Resort, opts.RoomType)
+	results.ResortCode, results.RoomCode = resort, roomType
+
+	chunks := monthChunks(opts.Start, opts.End, time.Now())
+	if len(chunks) == 0 {
+		return results, errors.New("availability range is outside the booking window")
+	}
+
+	nights := map[string]DayAvailability{}
+	for _, chunk := range chunks {
+		body := CalendarRequestBody{
+			Resort:    string(resort),
+			RoomType:  string(roomType),
+			StartDate: chunk.start.Format(dateFormat),
+			EndDate:   chunk.end.Format(dateFormat),
+		}
+
+		chunkResults, err := h.calendar(ctx, body)
+		if err != nil {
+			err = fmt.Errorf("failed to get availability from %s to %s: %w", body.StartDate, body.EndDate, err)
+			return results, err
+		}
+
+		if chunkResults.ResortCode != "" {
+			results.ResortCode = chunkResults.ResortCode
+		}
+		if chunkResults.RoomCode != "" {
+			results.RoomCode = chunkResults.RoomCode
+		}
+
+		for _, night := range chunkResults.Availability {
+			day := night.Day()
+			if day < body.StartDate || day > body.EndDate {
+				continue
+			}
+			nights[day] = night
+		}
+	}
+
+	for _, night := range nights {
+		results.Availability = append(results.Availability, night)
+	}
+	sortNights(results.Availability)
+
+	if h.scraper != nil {
+		h.scraper.observeNights(results)
+	}
+	return results, nil
+}
+
+// monthChunks splits start to end into spans that don't cross a month
+// boundary, clamped to the booking window as of now
+func monthChunks(start, end, now time.Time) []dateRange {
+	start = startOfDay(start)
+	end = startOfDay(end)
+
+	today := startOfDay(now)
+	if start.Before(today) {
+		start = today
+	}
+
+	windowEnd := startOfDay(bookingWindowEnd(now))
+	if end.After(windowEnd) {
+		end = windowEnd
+	}
+
+	chunks := []dateRange{}
+	for cur := start; !cur.After(end); {
+		y, m, _ := cur.Date()
+		monthEnd := time.Date(y, m+1, 0, 0, 0, 0, 0, cur.Location())
+
+		chunkEnd := monthEnd
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+		chunks = append(chunks, dateRange{start: cur, end: chunkEnd})
+
+		cur = monthEnd.AddDate(0, 0, 1)
+	}
+
+	return chunks
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func sortNights(nights []DayAvailability) {
+	sort.Slice(nights, func(i, j int) bool {
+		return nights[i].Day() < nights[j].Day()
+	})
+}
@@ -0,0 +1,157 @@
+package dvcscraper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMonthChunks(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.ParseInLocation(dateFormat, s, time.UTC)
+		if err != nil {
+			t.Fatalf("bad test date %q: %v", s, err)
+		}
+		return d
+	}
+	now := time.Date(2024, time.March, 10, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       [][2]string
+	}{
+		{
+			name:  "within one month",
+			start: day("2024-04-03"), end: day("2024-04-20"),
+			want: [][2]string{{"2024-04-03", "2024-04-20"}},
+		},
+		{
+			name:  "single night",
+			start: day("2024-05-31"), end: day("2024-05-31"),
+			want: [][2]string{{"2024-05-31", "2024-05-31"}},
+		},
+		{
+			name:  "crosses months",
+			start: day("2024-04-20"), end: day("2024-06-05"),
+			want: [][2]string{
+				{"2024-04-20", "2024-04-30"},
+				{"2024-05-01", "2024-05-31"},
+				{"2024-06-01", "2024-06-05"},
+			},
+		},
+		{
+			name:  "leap february",
+			start: day("2024-02-01"), end: day("2024-03-12"),
+			want: [][2]string{{"2024-03-10", "2024-03-12"}},
+		},
+		{
+			name:  "crosses year",
+			start: day("2024-12-30"), end: day("2025-01-02"),
+			want: [][2]string{
+				{"2024-12-30", "2024-12-31"},
+				{"2025-01-01", "2025-01-02"},
+			},
+		},
+		{
+			name:  "start clamped to today",
+			start: day("2024-03-01"), end: day("2024-04-02"),
+			want: [][2]string{
+				{"2024-03-10", "2024-03-31"},
+				{"2024-04-01", "2024-04-02"},
+			},
+		},
+		{
+			name:  "end clamped to booking window",
+			start: day("2025-01-20"), end: day("2025-06-01"),
+			want: [][2]string{
+				{"2025-01-20", "2025-01-31"},
+				{"2025-02-01", "2025-02-16"},
+			},
+		},
+		{
+			name:  "time of day ignored",
+			start: day("2024-04-03").Add(20 * time.Hour), end: day("2024-04-04").Add(time.Hour),
+			want: [][2]string{{"2024-04-03", "2024-04-04"}},
+		},
+		{
+			name:  "entirely in the past",
+			start: day("2024-01-01"), end: day("2024-02-01"),
+			want: [][2]string{},
+		},
+		{
+			name:  "entirely beyond the booking window",
+			start: day("2025-03-01"), end: day("2025-03-31"),
+			want: [][2]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := [][2]string{}
+			for _, chunk := range monthChunks(tt.start, tt.end, now) {
+				got = append(got, [2]string{chunk.start.Format(dateFormat), chunk.end.Format(dateFormat)})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortNights(t *testing.T) {
+	nights := []DayAvailability{
+		{Date: "2024-04-03T00:00:00"},
+		{Date: "2024-04-01"},
+		{Date: "2024-04-02T00:00:00"},
+	}
+	sortNights(nights)
+
+	for i, want := range []string{"2024-04-01", "2024-04-02", "2024-04-03"} {
+		if nights[i].Day() != want {
+			t.Fatalf("night %d is %s, want %s", i, nights[i].Day(), want)
+		}
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	now := time.Date(2024, time.March, 31, 15, 30, 0, 0, time.Local)
+
+	tests := []struct {
+		name      string
+		from, to  string
+		wantStart string
+		wantEnd   string
+		wantBound string
+	}{
+		{name: "defaults", wantStart: "2024-03-31", wantEnd: "2024-03-31"},
+		{name: "to today", to: "2024-03-31", wantStart: "2024-03-31", wantEnd: "2024-03-31"},
+		{name: "from only", from: "2024-05-10", wantStart: "2024-05-10", wantEnd: "2024-05-31"},
+		{name: "both", from: "2024-05-10", to: "2024-07-02", wantStart: "2024-05-10", wantEnd: "2024-07-02"},
+		{name: "bad from", from: "05/10/2024", wantBound: "from"},
+		{name: "bad to", to: "tomorrow", wantBound: "to"},
+		{name: "backwards", from: "2024-05-10", to: "2024-05-09", wantBound: "to"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := ParseDateRange(tt.from, tt.to, now)
+			if tt.wantBound != "" {
+				dateErr, ok := err.(*DateError)
+				if !ok || dateErr.Bound != tt.wantBound {
+					t.Fatalf("expected DateError for %s, got %v", tt.wantBound, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if start.Format(dateFormat) != tt.wantStart || end.Format(dateFormat) != tt.wantEnd {
+				t.Fatalf("got %s to %s, want %s to %s", start.Format(dateFormat), end.Format(dateFormat), tt.wantStart, tt.wantEnd)
+			}
+			if start.Hour() != 0 || start.Minute() != 0 {
+				t.Fatalf("start isn't midnight: %s", start)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package dvcscraper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/defaults"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/launcher/flags"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+const (
+	defaultWindowWidth  = 2560
+	defaultWindowHeight = 1400
+)
+
+// BrowserOptions controls how the browser is launched, or which running
+// browser to connect to, and how its pages present themselves to the site
+type BrowserOptions struct {
+	// RemoteURL connects to an already running browser instead of launching
+	// one, e.g. a sidecar container started with --remote-debugging-port.
+	// It's either the DevTools websocket URL or an http URL, host:port or
+	// port that serves /json/version. The launch options below are ignored
+	// and Close leaves the remote browser running. Defaults to the browser
+	// URL configured for rod, e.g. by a .rod file, if there is one.
+	RemoteURL string
+
+	// Headful shows the browser window. When it's false rod's default
+	// applies, which is headless unless "show" is set in a .rod file.
+	Headful bool
+	// UserDataDir is the browser profile directory. Defaults to a temporary
+	// one.
+	UserDataDir string
+	// Proxy is the proxy server to send traffic through, e.g.
+	// "socks5://127.0.0.1:1080" or "proxy.local:3128"
+	Proxy string
+	// Flags are extra command line switches, e.g. "--no-sandbox" or
+	// "--disable-gpu", passed as-is
+	Flags []string
+
+	// WindowWidth and WindowHeight size the window and the viewport used to
+	// log in. They default to 2560x1400.
+	WindowWidth  int
+	WindowHeight int
+
+	// Locale overrides the pages' locale and Accept-Language, e.g. "en-US"
+	Locale string
+	// Timezone overrides the pages' time zone, e.g. "America/New_York"
+	Timezone string
+	// UserAgent overrides the pages' user agent
+	UserAgent string
+}
+
+// windowSize returns the configured window size or the default
+func (b BrowserOptions) windowSize() (int, int) {
+	width, height := b.WindowWidth, b.WindowHeight
+	if width <= 0 {
+		width = defaultWindowWidth
+	}
+	if height <= 0 {
+		height = defaultWindowHeight
+	}
+	return width, height
+}
+
+// launcher maps the options onto a rod launcher for the browser at bin, or
+// rod's default browser when bin is empty
+func (b BrowserOptions) launcher(bin string) *launcher.Launcher {
+	l := launcher.New()
+	if b.Headful {
+		l = l.Headless(false)
+	}
+
+	if bin != "" {
+		l = l.Bin(bin)
+	}
+	if b.UserDataDir != "" {
+		l = l.UserDataDir(b.UserDataDir)
+	}
+	if b.Proxy != "" {
+		l = l.Proxy(b.Proxy)
+	}
+
+	width, height := b.windowSize()
+	l = l.Set("window-size", strconv.Itoa(width), strconv.Itoa(height))
+
+	if b.Locale != "" {
+		l = l.Set("lang", b.Locale)
+	}
+
+	for _, flag := range b.Flags {
+		name, value := parseFlag(flag)
+		if name == "" {
+			continue
+		}
+		if value == "" {
+			l = l.Set(flags.Flag(name))
+		} else {
+			l = l.Set(flags.Flag(name), value)
+		}
+	}
+
+	return l
+}
+
+// remoteURL returns RemoteURL, or rod's configured browser URL when it's
+// empty
+func (b BrowserOptions) remoteURL() string {
+	if b.RemoteURL != "" {
+		return b.RemoteURL
+	}
+	return defaults.URL
+}
+
+// controlURL resolves the remote URL to a DevTools websocket URL
+func (b BrowserOptions) controlURL() (string, error) {
+	u := strings.TrimSpace(b.remoteURL())
+	if strings.HasPrefix(u, "ws://") || strings.HasPrefix(u, "wss://") {
+		return u, nil
+	}
+
+	return launcher.ResolveURL(u)
+}
+
+// preparePage applies the page emulation overrides to a new page
+func (b BrowserOptions) preparePage(page *rod.Page) error {
+	if b.Locale != "" {
+		err := proto.EmulationSetLocaleOverride{Locale: b.Locale}.Call(page)
+		if err != nil {
+			err = fmt.Errorf("failed to override locale: %w", err)
+			return err
+		}
+	}
+
+	if b.Timezone != "" {
+		err := proto.EmulationSetTimezoneOverride{TimezoneID: b.Timezone}.Call(page)
+		if err != nil {
+			err = fmt.Errorf("failed to override timezone: %w", err)
+			return err
+		}
+	}
+
+	// the user agent override is also the only way to set Accept-Language
+	// and navigator.languages, so a locale alone reuses the browser's own
+	// user agent
+	if b.UserAgent != "" || b.Locale != "" {
+		userAgent := b.UserAgent
+		if userAgent == "" {
+			version, err := proto.BrowserGetVersion{}.Call(page)
+			if err != nil {
+				err = fmt.Errorf("failed to get browser user agent: %w", err)
+				return err
+			}
+			userAgent = version.UserAgent
+		}
+
+		err := page.SetUserAgent(&proto.NetworkSetUserAgentOverride{
+			UserAgent:      userAgent,
+			AcceptLanguage: b.Locale,
+		})
+		if err != nil {
+			err = fmt.Errorf("failed to override user agent: %w", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseFlag splits a "--name=value" switch into its name and value
+func parseFlag(flag string) (string, string) {
+	flag = strings.TrimLeft(strings.TrimSpace(flag), "-")
+	parts := strings.SplitN(flag, "=", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
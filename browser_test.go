@@ -0,0 +1,142 @@
+package dvcscraper
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-rod/rod/lib/defaults"
+	"github.com/go-rod/rod/lib/launcher/flags"
+)
+
+func TestParseFlag(t *testing.T) {
+	tests := []struct {
+		flag      string
+		wantName  string
+		wantValue string
+	}{
+		{flag: "--no-sandbox", wantName: "no-sandbox"},
+		{flag: "disable-gpu", wantName: "disable-gpu"},
+		{flag: " -single-dash ", wantName: "single-dash"},
+		{flag: "--proxy-bypass-list=*.local;10.*", wantName: "proxy-bypass-list", wantValue: "*.local;10.*"},
+		{flag: "--js-flags=--max-old-space-size=512", wantName: "js-flags", wantValue: "--max-old-space-size=512"},
+		{flag: "--empty=", wantName: "empty"},
+		{flag: "--", wantName: ""},
+		{flag: "", wantName: ""},
+	}
+
+	for _, tt := range tests {
+		name, value := parseFlag(tt.flag)
+		if name != tt.wantName || value != tt.wantValue {
+			t.Fatalf("parseFlag(%q) = %q, %q, want %q, %q", tt.flag, name, value, tt.wantName, tt.wantValue)
+		}
+	}
+}
+
+// setShow sets rod's default for showing the browser for the rest of the test
+func setShow(t *testing.T, show bool) {
+	old := defaults.Show
+	defaults.Show = show
+	t.Cleanup(func() { defaults.Show = old })
+}
+
+func TestLauncherHeadless(t *testing.T) {
+	tests := []struct {
+		name         string
+		show         bool
+		headful      bool
+		wantHeadless bool
+	}{
+		{name: "rod default", wantHeadless: true},
+		{name: "shown by rod config", show: true, wantHeadless: false},
+		{name: "headful", headful: true, wantHeadless: false},
+		{name: "headful and shown", show: true, headful: true, wantHeadless: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setShow(t, tt.show)
+
+			l := BrowserOptions{Headful: tt.headful}.launcher("")
+			if got := l.Has(flags.Headless); got != tt.wantHeadless {
+				t.Fatalf("headless %v, want %v", got, tt.wantHeadless)
+			}
+		})
+	}
+}
+
+func TestLauncherFlags(t *testing.T) {
+	l := BrowserOptions{
+		UserDataDir: "/tmp/dvc-profile",
+		Proxy:       "socks5://127.0.0.1:1080",
+		Flags:       []string{"--no-sandbox", "--", "--disable-features=Translate"},
+		WindowWidth: 1280,
+		Locale:      "en-GB",
+	}.launcher("/usr/bin/chromium")
+
+	tests := []struct {
+		flag flags.Flag
+		want []string
+	}{
+		{flag: flags.Bin, want: []string{"/usr/bin/chromium"}},
+		{flag: flags.UserDataDir, want: []string{"/tmp/dvc-profile"}},
+		{flag: flags.ProxyServer, want: []string{"socks5://127.0.0.1:1080"}},
+		{flag: "window-size", want: []string{"1280", "1400"}},
+		{flag: "lang", want: []string{"en-GB"}},
+		{flag: flags.NoSandbox, want: []string{}},
+		{flag: "disable-features", want: []string{"Translate"}},
+	}
+
+	for _, tt := range tests {
+		got, ok := l.GetFlags(tt.flag)
+		if !ok {
+			t.Fatalf("flag %s not set", tt.flag)
+		}
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("flag %s = %q, want %q", tt.flag, got, tt.want)
+		}
+	}
+}
+
+func TestRemoteURL(t *testing.T) {
+	old := defaults.URL
+	t.Cleanup(func() { defaults.URL = old })
+
+	tests := []struct {
+		name       string
+		rodURL     string
+		remoteURL  string
+		want       string
+		wantRemote bool
+	}{
+		{name: "launch", want: ""},
+		{name: "option", remoteURL: "ws://browser:9222/devtools/browser/abc", want: "ws://browser:9222/devtools/browser/abc", wantRemote: true},
+		{name: "rod config", rodURL: "ws://sidecar:9222/devtools/browser/def", want: "ws://sidecar:9222/devtools/browser/def", wantRemote: true},
+		{name: "option wins", rodURL: "ws://sidecar:9222/x", remoteURL: "wss://browser/y", want: "wss://browser/y", wantRemote: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults.URL = tt.rodURL
+			opts := BrowserOptions{RemoteURL: tt.remoteURL}
+
+			if got := opts.remoteURL(); got != tt.want {
+				t.Fatalf("remote URL %q, want %q", got, tt.want)
+			}
+			if !tt.wantRemote {
+				return
+			}
+
+			// websocket URLs are used as they are, without asking the browser
+			u, err := opts.controlURL()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if u != tt.want {
+				t.Fatalf("control URL %q, want %q", u, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+// Package catalog describes DVC resorts and their room types, mapping between
+// the codes the booking API uses and the names shown on the DVC website
+package catalog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResortCode identifies a resort in the booking API, e.g. "BLT"
+type ResortCode string
+
+// RoomTypeCode identifies a room type within a resort in the booking API
+type RoomTypeCode string
+
+// View is what a room type looks out on
+type View string
+
+// Room views
+const (
+	ViewStandard  View = "standard"
+	ViewPreferred View = "preferred"
+	ViewLake      View = "lake"
+	ViewThemePark View = "theme-park"
+	ViewSavanna   View = "savanna"
+	ViewOcean     View = "ocean"
+)
+
+// Resort is a DVC resort
+type Resort struct {
+	Code ResortCode `json:"code"`
+	Name string     `json:"name"`
+	// DisplayNames are other names the DVC website uses for the resort, such
+	// as those on the add-on points page
+	DisplayNames []string `json:"displayNames,omitempty"`
+	// HomeResort is true when members can buy points with this resort as
+	// their home resort directly from DVC
+	HomeResort bool `json:"homeResort"`
+}
+
+// RoomType is a bookable room type at a resort
+type RoomType struct {
+	Resort   ResortCode   `json:"resort"`
+	Code     RoomTypeCode `json:"code"`
+	Name     string       `json:"name"`
+	Capacity int          `json:"capacity"`
+	// Bedrooms is zero for studios and inn rooms
+	Bedrooms int  `json:"bedrooms"`
+	View     View `json:"view"`
+}
+
+// The catalog is maintained by hand; add resorts and room types here once
+// their codes have been checked against the booking API. Resorts are listed so
+// the names on the DVC website can be matched to codes. Only room types seen in
+// booking API responses are listed, so sweeps don't spend requests on guessed
+// codes; other codes, including those at resorts missing here such as the
+// Villas at Disneyland Hotel and the Cabins at Fort Wilderness, can still be
+// requested by code.
+var resorts = []Resort{
+	{"AKV", "Disney's Animal Kingdom Villas – Jambo House", nil, true},
+	{"AKK", "Disney's Animal Kingdom Villas – Kidani Village", nil, true},
+	{"AUL", "Aulani, Disney Vacation Club Villas, Ko Olina, Hawai‘i", nil, true},
+	{"BCV", "Disney's Beach Club Villas", nil, true},
+	{"BLT", "Bay Lake Tower at Disney's Contemporary Resort", nil, true},
+	{"BRV", "Boulder Ridge Villas at Disney's Wilderness Lodge", nil, true},
+	{"BWV", "Disney's BoardWalk Villas", nil, true},
+	{"CCV", "Copper Creek Villas & Cabins at Disney's Wilderness Lodge", nil, true},
+	{"HHI", "Disney's Hilton Head Island Resort", nil, true},
+	{"OKW", "Disney's Old Key West Resort", nil, true},
+	{"PVB", "Disney's Polynesian Villas & Bungalows", nil, true},
+	{"RIV", "Disney's Riviera Resort", nil, true},
+	{"SSR", "Disney's Saratoga Springs Resort", nil, true},
+	{"VB", "Disney's Vero Beach Resort", nil, true},
+	{"VGC", "The Villas at Disney's Grand Californian Hotel & Spa", nil, false},
+	{"VGF", "The Villas at Disney's Grand Floridian Resort", nil, true},
+}
+
+var roomTypes = []RoomType{
+	{"BLT", "4O", "Deluxe Studio", 4, 0, ViewStandard},
+}
+
+// Resorts returns every resort in the catalog
+func Resorts() []Resort {
+	return append([]Resort{}, resorts...)
+}
+
+// ResortByCode looks up a resort by its booking API code
+func ResortByCode(code ResortCode) (Resort, bool) {
+	for _, resort := range resorts {
+		if strings.EqualFold(string(resort.Code), string(code)) {
+			return resort, true
+		}
+	}
+	return Resort{}, false
+}
+
+// ResortByName looks up a resort by any name the DVC website uses for it.
+// Differences in case, whitespace, apostrophes and dashes are ignored.
+func ResortByName(name string) (Resort, bool) {
+	want := normalizeName(name)
+	for _, resort := range resorts {
+		if normalizeName(resort.Name) == want {
+			return resort, true
+		}
+		for _, display := range resort.DisplayNames {
+			if normalizeName(display) == want {
+				return resort, true
+			}
+		}
+	}
+	return Resort{}, false
+}
+
+// RoomTypes returns every room type in the catalog
+func RoomTypes() []RoomType {
+	return append([]RoomType{}, roomTypes...)
+}
+
+// RoomTypesAt returns the room types at a resort
+func RoomTypesAt(code ResortCode) []RoomType {
+	matched := []RoomType{}
+	for _, roomType := range roomTypes {
+		if strings.EqualFold(string(roomType.Resort), string(code)) {
+			matched = append(matched, roomType)
+		}
+	}
+	return matched
+}
+
+// RoomTypeByCode looks up a room type at a resort
+func RoomTypeByCode(resort ResortCode, code RoomTypeCode) (RoomType, bool) {
+	for _, roomType := range RoomTypesAt(resort) {
+		if strings.EqualFold(string(roomType.Code), string(code)) {
+			return roomType, true
+		}
+	}
+	return RoomType{}, false
+}
+
+// Validate returns an error if the resort or room type isn't in the catalog
+func Validate(resort ResortCode, roomType RoomTypeCode) error {
+	if _, ok := ResortByCode(resort); !ok {
+		return fmt.Errorf("unknown resort code '%s'", resort)
+	}
+
+	if _, ok := RoomTypeByCode(resort, roomType); !ok {
+		return fmt.Errorf("unknown room type '%s' at %s", roomType, resort)
+	}
+
+	return nil
+}
+
+// Canonical returns the codes spelled as they are in the catalog, so that
+// lookups, which ignore case, and the booking API agree. Codes the catalog
+// doesn't know are returned trimmed but otherwise as given, along with the
+// error Validate reports for them.
+func Canonical(resort ResortCode, roomType RoomTypeCode) (ResortCode, RoomTypeCode, error) {
+	resort = ResortCode(strings.TrimSpace(string(resort)))
+	roomType = RoomTypeCode(strings.TrimSpace(string(roomType)))
+
+	known, ok := ResortByCode(resort)
+	if !ok {
+		return resort, roomType, fmt.Errorf("unknown resort code '%s'", resort)
+	}
+	resort = known.Code
+
+	room, ok := RoomTypeByCode(resort, roomType)
+	if !ok {
+		return resort, roomType, fmt.Errorf("unknown room type '%s' at %s", roomType, resort)
+	}
+
+	return resort, room.Code, nil
+}
+
+// Name returns the resort's name, or the code itself if it's unknown
+func (c ResortCode) Name() string {
+	if resort, ok := ResortByCode(c); ok {
+		return resort.Name
+	}
+	return string(c)
+}
+
+var nameReplacer = strings.NewReplacer(
+	"‘", "'",
+	"’", "'",
+	"–", "-",
+	"—", "-",
+)
+
+func normalizeName(name string) string {
+	name = nameReplacer.Replace(strings.ToLower(name))
+	return strings.Join(strings.Fields(name), " ")
+}
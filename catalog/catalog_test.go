@@ -0,0 +1,32 @@
+package catalog
+
+import "testing"
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name         string
+		resort       ResortCode
+		roomType     RoomTypeCode
+		wantResort   ResortCode
+		wantRoomType RoomTypeCode
+		wantErr      bool
+	}{
+		{name: "exact", resort: "BLT", roomType: "4O", wantResort: "BLT", wantRoomType: "4O"},
+		{name: "lower case", resort: "blt", roomType: "4o", wantResort: "BLT", wantRoomType: "4O"},
+		{name: "padded", resort: " BLT ", roomType: " 4O", wantResort: "BLT", wantRoomType: "4O"},
+		{name: "unknown room type", resort: "blt", roomType: "zz9", wantResort: "BLT", wantRoomType: "zz9", wantErr: true},
+		{name: "unknown resort", resort: "xyz", roomType: "4o", wantResort: "xyz", wantRoomType: "4o", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resort, roomType, err := Canonical(tt.resort, tt.roomType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if resort != tt.wantResort || roomType != tt.wantRoomType {
+				t.Fatalf("got %s/%s, want %s/%s", resort, roomType, tt.wantResort, tt.wantRoomType)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+	"github.com/lineleader/dvc-scraper/catalog"
+	"github.com/lineleader/dvc-scraper/output"
+)
+
+func runAvailability(ctx context.Context, args []string) error {
+	flags := newFlagSet("availability", "--resort CODE --room CODE [flags]", "Show nightly availability of one room type and record it in the history.")
+	resort := flags.String("resort", "", "resort code, e.g. BLT (required)")
+	room := flags.String("room", "", "room type code, e.g. 4O (required)")
+	from := flags.String("from", "", "first night as YYYY-MM-DD; defaults to today")
+	to := flags.String("to", "", "last night as YYYY-MM-DD; defaults to the end of the --from month")
+	open := flags.Bool("open", false, "only show nights with rooms")
+	historyFile := flags.String("history", historyPath(), "history database; empty to skip recording")
+	formatName := formatFlag(flags)
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	format, err := parseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	if *resort == "" || *room == "" {
+		return usageError{"--resort and --room are required"}
+	}
+	opts := dvcscraper.AvailabilityRangeOptions{
+		Resort:   catalog.ResortCode(strings.ToUpper(*resort)),
+		RoomType: catalog.RoomTypeCode(strings.ToUpper(*room)),
+	}
+	opts.Start, opts.End, err = dateFlags(*from, *to)
+	if err != nil {
+		return err
+	}
+	err = opts.Validate()
+	if err != nil {
+		return usageError{err.Error()}
+	}
+
+	store, err := openHistory(*historyFile)
+	if err != nil {
+		return err
+	}
+	if store != nil {
+		defer store.Close()
+	}
+
+	scraper, err := newScraper(ctx, nil, otpProvider())
+	if err != nil {
+		return err
+	}
+	defer closeScraper(scraper)
+
+	handle, err := scraper.NewAvailabilityHandleContext(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to get availability handle: %w", err)
+		return err
+	}
+	defer handle.Close()
+
+	results, err := handle.GetAvailabilityRangeContext(ctx, opts)
+	if err != nil {
+		err = fmt.Errorf("failed to get availability: %w", err)
+		return err
+	}
+
+	if store != nil {
+		err = store.Record(ctx, results, time.Now())
+		if err != nil {
+			err = fmt.Errorf("failed to record availability: %w", err)
+			return err
+		}
+	}
+
+	if *open {
+		open := []dvcscraper.DayAvailability{}
+		for _, night := range results.Availability {
+			if night.Rooms > 0 {
+				open = append(open, night)
+			}
+		}
+		results.Availability = open
+	}
+
+	return output.Write(os.Stdout, format, output.Availability(results))
+}
+
+// dateFlags parses --from and --to. From defaults to today and to defaults to
+// the end of from's month.
+func dateFlags(from, to string) (time.Time, time.Time, error) {
+	start, end, err := dvcscraper.ParseDateRange(from, to, time.Now())
+	var dateErr *dvcscraper.DateError
+	if errors.As(err, &dateErr) {
+		return start, end, usageError{fmt.Sprintf("invalid --%s '%s': %s", dateErr.Bound, dateErr.Value, dateErr.Reason)}
+	}
+	return start, end, err
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+)
+
+func runLogin(ctx context.Context, args []string) error {
+	flags := newFlagSet("login", "[flags]", "Log in, prompting for a one-time passcode if asked, and save the session.")
+	fresh := flags.Bool("fresh", false, "discard the saved session before logging in")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	opts, err := scraperOptions(nil, otpProvider())
+	if err != nil {
+		return err
+	}
+	// a fresh login replaces the saved session, so don't fail on one that
+	// can't be read
+	opts.SkipSession = *fresh
+
+	scraper, err := startScraper(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer closeScraper(scraper)
+
+	if *fresh {
+		err = scraper.ClearSession()
+		if err != nil {
+			err = fmt.Errorf("failed to clear session: %w", err)
+			return err
+		}
+	}
+
+	err = scraper.LoginContext(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to log in: %w", err)
+		return err
+	}
+
+	fmt.Println("Logged in")
+	return nil
+}
+
+func runSession(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "status" {
+		if len(args) > 0 && isHelp(args[0]) {
+			args = []string{"-help"}
+		} else {
+			return usageError{"want 'session status'"}
+		}
+	} else {
+		args = args[1:]
+	}
+
+	flags := newFlagSet("session status", "[flags]", "Report whether the saved session can still be used. Exits 5 if it has expired.")
+	probe := flags.Bool("probe", false, "confirm the session by loading a members-only page")
+	expiringWithin := flags.Duration("expiring-within", 30*time.Minute, "how close to expiry counts as expiring soon")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	scraper, err := newScraper(ctx, nil, otpProvider())
+	if err != nil {
+		return err
+	}
+	defer closeScraper(scraper)
+
+	status, err := scraper.SessionStatusContext(ctx, dvcscraper.SessionStatusOptions{
+		ExpiringWithin: *expiringWithin,
+		Probe:          *probe,
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to check session: %w", err)
+		return err
+	}
+
+	fmt.Println("State:", status.State)
+	if !status.ExpiresAt.IsZero() {
+		fmt.Printf("Expires: %s (in %s)\n", status.ExpiresAt.Format(time.RFC3339), time.Until(status.ExpiresAt).Round(time.Second))
+	}
+	if len(status.MissingCookies) > 0 {
+		fmt.Println("Missing cookies:", status.MissingCookies)
+	}
+	fmt.Println("Probed:", status.Probed)
+
+	if status.State == dvcscraper.SessionExpired {
+		return dvcscraper.ErrSessionExpired
+	}
+	return nil
+}
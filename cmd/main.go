@@ -1,101 +1,370 @@
+// Command dvc-scraper logs in to the DVC member site and reports purchase
+// prices, availability and stays from the command line.
+//
+// Credentials and session keys are read from the environment or a .env file:
+// see .env.example.
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
-	"time"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
 
 	"github.com/gobuffalo/envy"
 	dvcscraper "github.com/lineleader/dvc-scraper"
+	"github.com/lineleader/dvc-scraper/history"
+	"github.com/lineleader/dvc-scraper/notify"
+	"github.com/lineleader/dvc-scraper/output"
 )
 
-var currentPrices = map[string]float64{
-	"Aulani, Disney Vacation Club Villas,\nKo Olina, Hawai‘i":   201,
-	"Disney's Riviera Resort":                                   201,
-	"Copper Creek Villas & Cabins at Disney's Wilderness Lodge": 225,
-	"Bay Lake Tower at Disney's Contemporary Resort":            245,
-	"Boulder Ridge Villas at Disney's Wilderness Lodge":         186,
-	"Disney's Animal Kingdom Villas – Jambo House":              186,
-	"Disney's Animal Kingdom Villas – Kidani Village":           186,
-	"Disney's Beach Club Villas":                                245,
-	"Disney's BoardWalk Villas":                                 210,
-	"Disney's Hilton Head Island Resort":                        140,
-	"Disney's Old Key West Resort":                              165,
-	"Disney's Polynesian Villas & Bungalows":                    250,
-	"Disney's Saratoga Springs Resort":                          165,
-	"Disney's Vero Beach Resort":                                125,
-	"The Villas at Disney's Grand Floridian Resort":             255,
+// Exit codes, by kind of failure
+const (
+	exitOK             = 0
+	exitError          = 1
+	exitUsage          = 2
+	exitLoginRejected  = 3
+	exitOTP            = 4
+	exitSessionExpired = 5
+	exitRateLimited    = 6
+	exitSiteChanged    = 7
+	exitAPIResponse    = 8
+	exitBrowserLaunch  = 9
+)
+
+const exitCodesHelp = `Exit codes:
+  0  success
+  1  other error
+  2  invalid usage
+  3  login rejected
+  4  one-time passcode timed out or was rejected
+  5  session expired
+  6  rate limited by the site
+  7  site changed or an expected element was missing
+  8  unexpected API response
+  9  browser failed to launch
+`
+
+// command is a subcommand of the CLI
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
 }
 
+var commands = []command{
+	{"login", "log in and save the session", runLogin},
+	{"session", "inspect the saved session (session status)", runSession},
+	{"prices", "show purchase prices and record changes", runPrices},
+	{"availability", "show availability of a room type", runAvailability},
+	{"stays", "find bookable stays", runStays},
+	{"watch", "poll availability and report changes", runWatch},
+	{"screenshot", "save a screenshot of a member site page", runScreenshot},
+	{"serve", "serve results as a JSON API over HTTP", runServe},
+}
+
+// usageError is returned for bad arguments
+type usageError struct {
+	msg string
+}
+
+func (u usageError) Error() string { return u.msg }
+
 func main() {
-	email := envy.Get("EMAIL", "")
-	password := envy.Get("PASSWORD", "")
-	// email := faker.Email()
-	// password := faker.Password()
-
-	scraper, err := dvcscraper.New(dvcscraper.ScraperOptions{
-		Email:    email,
-		Password: password,
-	})
-	if err != nil {
-		err = fmt.Errorf("failed to start scraper: %w", err)
-		log.Fatal(err)
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 || isHelp(args[0]) {
+		usage(os.Stdout)
+		return exitOK
 	}
-	defer scraper.Close()
-	fmt.Println("Started scraper")
 
-	// prices(&scraper)
-	// avail(&scraper)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		err := cmd.run(ctx, args[1:])
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.name, err)
+		}
+		return exitCode(err)
+	}
 
-	err = scraper.AuthenticatedNavigate(
-		"https://disneyvacationclub.disney.go.com/home/",
-		".news-alert-header",
-	)
+	fmt.Fprintf(os.Stderr, "unknown command '%s'\n\n", args[0])
+	usage(os.Stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: dvc-scraper <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-13s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'dvc-scraper <command> --help' for a command's flags.")
+	fmt.Fprintln(w)
+	fmt.Fprint(w, exitCodesHelp)
+}
+
+// exitCode maps an error to the exit code for its kind
+func exitCode(err error) int {
+	var usageErr usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usageErr):
+		return exitUsage
+	case dvcscraper.IsOTPTimeout(err), dvcscraper.IsOTPRejected(err):
+		return exitOTP
+	case errors.Is(err, dvcscraper.ErrLoginRejected):
+		return exitLoginRejected
+	case errors.Is(err, dvcscraper.ErrSessionExpired):
+		return exitSessionExpired
+	case errors.Is(err, dvcscraper.ErrRateLimited):
+		return exitRateLimited
+	case errors.Is(err, dvcscraper.ErrSiteChanged), errors.Is(err, dvcscraper.ErrSelectorNotFound):
+		return exitSiteChanged
+	case errors.Is(err, dvcscraper.ErrAPIResponse):
+		return exitAPIResponse
+	case errors.Is(err, dvcscraper.ErrBrowserLaunch):
+		return exitBrowserLaunch
+	}
+	return exitError
+}
+
+// newFlagSet returns a FlagSet whose usage lists the command's flags and the
+// exit codes
+func newFlagSet(name, args, summary string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		w := flags.Output()
+		fmt.Fprintf(w, "Usage: dvc-scraper %s %s\n\n%s\n\nFlags:\n", name, args, summary)
+		flags.PrintDefaults()
+		fmt.Fprintln(w)
+		fmt.Fprint(w, exitCodesHelp)
+	}
+	return flags
+}
+
+// formatFlag adds --format to flags
+func formatFlag(flags *flag.FlagSet) *string {
+	names := []string{}
+	for _, format := range output.Formats() {
+		names = append(names, string(format))
+	}
+	return flags.String("format", string(output.Table), "output format: "+strings.Join(names, ", "))
+}
+
+// parseFormat checks a --format value
+func parseFormat(value string) (output.Format, error) {
+	format, err := output.ParseFormat(value)
 	if err != nil {
-		err = fmt.Errorf("failed to visit dashboard: %w", err)
-		log.Println(err)
-		return
+		return format, usageError{err.Error()}
+	}
+	return format, nil
+}
+
+// parseFlags parses args, turning bad flags into a usageError
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	} else if err != nil {
+		return usageError{err.Error()}
 	}
+	return nil
+}
 
-	scraper.Screenshot("dashboard.png")
-	fmt.Println("Done.")
+func isHelp(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help" || arg == "help"
 }
 
-func prices(scraper *dvcscraper.Scraper) {
-	prices, err := scraper.GetPurchasePrices()
+// newScraper starts a Scraper configured from the environment. recorder may
+// be nil.
+func newScraper(ctx context.Context, recorder dvcscraper.MetricsRecorder, otp dvcscraper.OTPProvider) (*dvcscraper.Scraper, error) {
+	opts, err := scraperOptions(recorder, otp)
 	if err != nil {
-		err = fmt.Errorf("failed to get purchase prices: %w", err)
-		log.Fatal(err)
+		return nil, err
 	}
 
-	for _, price := range prices {
-		currentPrice, ok := currentPrices[price.Name]
-		if !ok {
-			fmt.Println("\nCurrent price not found!!", price.Name, price.PricePerPoint)
-			continue
+	return startScraper(ctx, opts)
+}
+
+// scraperOptions reads the Scraper's configuration from the environment
+func scraperOptions(recorder dvcscraper.MetricsRecorder, otp dvcscraper.OTPProvider) (dvcscraper.ScraperOptions, error) {
+	opts := dvcscraper.ScraperOptions{
+		Credentials: dvcscraper.EnvCredentials{},
+		OTPProvider: otp,
+		Metrics:     recorder,
+		Browser:     browserOptions(),
+		ProfileDir:  envy.Get("PROFILE_DIR", ""),
+	}
+
+	if raw := envy.Get("SESSION_KEYS", ""); raw != "" {
+		keys, err := dvcscraper.ParseSessionKeys(raw)
+		if err != nil {
+			err = fmt.Errorf("failed to parse session keys: %w", err)
+			return opts, err
 		}
+		opts.SessionKeys = keys
+	}
 
-		if currentPrice != price.PricePerPoint {
-			fmt.Printf("Price difference found!!\n%s\n$%.2f => $%.2f\n\n", price.Name, currentPrice, price.PricePerPoint)
+	return opts, nil
+}
+
+// startScraper starts a Scraper with opts. A Scraper that fails to start,
+// e.g. because the saved session can't be read, is closed without touching
+// the saved session.
+func startScraper(ctx context.Context, opts dvcscraper.ScraperOptions) (*dvcscraper.Scraper, error) {
+	scraper, err := dvcscraper.NewContext(ctx, opts)
+	if err != nil {
+		closeScraper(&scraper)
+		if unreadableSession(err) {
+			err = fmt.Errorf("failed to start scraper: %w (run 'login --fresh' to replace the saved session)", err)
+			return nil, err
 		}
+		err = fmt.Errorf("failed to start scraper: %w", err)
+		return nil, err
+	}
+
+	return &scraper, nil
+}
+
+// unreadableSession reports whether err is a saved session that can't be
+// decrypted or decoded
+func unreadableSession(err error) bool {
+	var corrupt *dvcscraper.SessionCorruptError
+	return errors.Is(err, dvcscraper.ErrUnknownSessionKey) ||
+		errors.Is(err, dvcscraper.ErrPlaintextSession) ||
+		errors.As(err, &corrupt)
+}
+
+// otpProvider reads passcodes from the IMAP mailbox configured by imapOTP, or
+// else asks on the terminal
+func otpProvider() dvcscraper.OTPProvider {
+	if mailbox := imapOTP(); mailbox != nil {
+		return mailbox
+	}
+	return dvcscraper.NewStdinOTP()
+}
+
+// imapOTP returns the passcode mailbox set by IMAP_ADDR, IMAP_USERNAME and
+// IMAP_PASSWORD, or nil when IMAP_ADDR isn't set
+func imapOTP() *dvcscraper.IMAPOTP {
+	addr := envy.Get("IMAP_ADDR", "")
+	if addr == "" {
+		return nil
+	}
+
+	return &dvcscraper.IMAPOTP{
+		Addr:     addr,
+		Username: envy.Get("IMAP_USERNAME", ""),
+		Password: envy.Get("IMAP_PASSWORD", ""),
+	}
+}
+
+// browserOptions reads the browser settings from the environment.
+// BROWSER_FLAGS is a space separated list of extra switches.
+func browserOptions() dvcscraper.BrowserOptions {
+	return dvcscraper.BrowserOptions{
+		RemoteURL:   envy.Get("BROWSER_REMOTE_URL", ""),
+		Headful:     envy.Get("BROWSER_HEADFUL", "") != "",
+		UserDataDir: envy.Get("BROWSER_USER_DATA_DIR", ""),
+		Proxy:       envy.Get("BROWSER_PROXY", ""),
+		Flags:       strings.Fields(envy.Get("BROWSER_FLAGS", "")),
+		Locale:      envy.Get("BROWSER_LOCALE", ""),
+		Timezone:    envy.Get("BROWSER_TIMEZONE", ""),
+		UserAgent:   envy.Get("BROWSER_USER_AGENT", ""),
 	}
 }
 
-func avail(scraper *dvcscraper.Scraper) {
-	handle, err := scraper.NewAvailabilityHandle()
+// closeScraper saves the session, logging any failure since the command's
+// own result matters more
+func closeScraper(scraper *dvcscraper.Scraper) {
+	err := scraper.Close()
 	if err != nil {
-		err = fmt.Errorf("failed to get availability handle: %w", err)
-		log.Fatal(err)
+		log.Println("failed to close scraper:", err)
 	}
+}
 
-	results, err := handle.GetAvailability(dvcscraper.AvailabilityOptions{
-		Resort:   "BLT",
-		RoomType: "4O",
-		Date:     time.Now(),
+// printNotifier writes messages to w
+func printNotifier(w io.Writer) notify.Notifier {
+	return notify.NotifierFunc(func(ctx context.Context, msg notify.Message) error {
+		_, err := fmt.Fprintf(w, "%s\n%s\n\n", msg.Title, msg.Body)
+		return err
 	})
+}
+
+// notifier prints messages to w and, when sinks is set, also sends them to
+// the sinks configured in the environment
+func notifier(w io.Writer, sinks bool) notify.Notifier {
+	printer := printNotifier(w)
+	if !sinks {
+		return printer
+	}
+
+	configured := notify.Multi{}
+	if url := envy.Get("NOTIFY_WEBHOOK_URL", ""); url != "" {
+		configured = append(configured, notify.Webhook{URL: url})
+	}
+	if url := envy.Get("NOTIFY_SLACK_URL", ""); url != "" {
+		configured = append(configured, notify.Slack{WebhookURL: url})
+	}
+	if topic := envy.Get("NOTIFY_NTFY_TOPIC", ""); topic != "" {
+		configured = append(configured, notify.Ntfy{Server: envy.Get("NOTIFY_NTFY_SERVER", ""), Topic: topic})
+	}
+
+	if len(configured) == 0 {
+		log.Println("no notification sinks configured; printing only")
+		return printer
+	}
+	return notify.Multi{printer, notify.NewDedupe(configured, notify.DefaultDedupeWindow)}
+}
+
+func historyPath() string {
+	return envy.Get("HISTORY_DB", "dvc-history.db")
+}
+
+// openHistory opens the history database, or returns nil when path is empty
+func openHistory(path string) (*history.Store, error) {
+	if path == "" {
+		return nil, nil
+	}
 
-	fmt.Println("Err:", err)
-	fmt.Println("Res:", results)
+	store, err := history.Open(path)
+	if err != nil {
+		err = fmt.Errorf("failed to open history: %w", err)
+		return nil, err
+	}
+	return store, nil
+}
 
-	fmt.Println("Done.")
+// listFlag splits a comma separated flag into upper case values
+func listFlag(value string) []string {
+	values := []string{}
+	for _, v := range strings.Split(value, ",") {
+		v = strings.ToUpper(strings.TrimSpace(v))
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	sort.Strings(values)
+	return values
 }
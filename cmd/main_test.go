@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+)
+
+// otpError is a login that failed at the one-time passcode
+type otpError struct {
+	timedOut, rejected bool
+}
+
+func (e otpError) Error() string     { return "one-time passcode failed" }
+func (e otpError) OTPTimedOut() bool { return e.timedOut }
+func (e otpError) OTPRejected() bool { return e.rejected }
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "success", err: nil, want: exitOK},
+		{name: "other", err: errors.New("boom"), want: exitError},
+		{name: "usage", err: fmt.Errorf("bad flags: %w", usageError{"--resort is required"}), want: exitUsage},
+		{name: "login rejected", err: fmt.Errorf("failed to log in: %w", dvcscraper.ErrLoginRejected), want: exitLoginRejected},
+		{name: "otp timed out", err: fmt.Errorf("failed to log in: %w", otpError{timedOut: true}), want: exitOTP},
+		{name: "otp rejected", err: otpError{rejected: true}, want: exitOTP},
+		{name: "session expired", err: &dvcscraper.APIError{Status: http.StatusUnauthorized}, want: exitSessionExpired},
+		{name: "rate limited", err: &dvcscraper.APIError{Status: http.StatusTooManyRequests}, want: exitRateLimited},
+		{name: "site changed", err: fmt.Errorf("failed to parse: %w", dvcscraper.ErrSiteChanged), want: exitSiteChanged},
+		{name: "selector missing", err: &dvcscraper.SelectorError{Selector: "#x", Err: errors.New("timeout")}, want: exitSiteChanged},
+		{name: "api response", err: &dvcscraper.APIError{Status: http.StatusInternalServerError}, want: exitAPIResponse},
+		{name: "browser launch", err: fmt.Errorf("failed to start: %w", dvcscraper.ErrBrowserLaunch), want: exitBrowserLaunch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Fatalf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	// each of these fails or finishes before a browser starts
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "no command", args: nil, want: exitOK},
+		{name: "help", args: []string{"help"}, want: exitOK},
+		{name: "help flag", args: []string{"--help"}, want: exitOK},
+		{name: "unknown command", args: []string{"book"}, want: exitUsage},
+		{name: "command help", args: []string{"stays", "--help"}, want: exitOK},
+		{name: "unknown flag", args: []string{"stays", "--nights", "3"}, want: exitUsage},
+		{name: "bad flag value", args: []string{"stays", "--min-nights", "three"}, want: exitUsage},
+		{name: "missing required flag", args: []string{"availability", "--resort", "BLT"}, want: exitUsage},
+		{name: "bad format", args: []string{"stays", "--format", "xml"}, want: exitUsage},
+		{name: "bad sort", args: []string{"stays", "--sort", "price"}, want: exitUsage},
+		{name: "bad date", args: []string{"stays", "--from", "April"}, want: exitUsage},
+		{name: "no matching room types", args: []string{"stays", "--resort", "ZZZ"}, want: exitUsage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(tt.args); got != tt.want {
+				t.Fatalf("run(%q) = %d, want %d", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListFlag(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{value: "", want: []string{}},
+		{value: "blt", want: []string{"BLT"}},
+		{value: " vgf, blt,,akv ", want: []string{"AKV", "BLT", "VGF"}},
+	}
+
+	for _, tt := range tests {
+		if got := listFlag(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("listFlag(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestUnreadableSession(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "unknown key", err: fmt.Errorf("failed to read cookies: %w", dvcscraper.ErrUnknownSessionKey), want: true},
+		{name: "plaintext", err: fmt.Errorf("failed to read cookies: %w", dvcscraper.ErrPlaintextSession), want: true},
+		{name: "corrupt", err: fmt.Errorf("failed to read cookies: %w", &dvcscraper.SessionCorruptError{Reason: "bad json"}), want: true},
+		{name: "launch", err: errors.New("failed to launch browser"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unreadableSession(tt.err); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/lineleader/dvc-scraper/notify"
+	"github.com/lineleader/dvc-scraper/output"
+)
+
+func runPrices(ctx context.Context, args []string) error {
+	flags := newFlagSet("prices", "[flags]", "Show purchase prices per point, record them and report changes since the last run.")
+	historyFile := flags.String("history", historyPath(), "history database; empty to skip recording")
+	notifyChanges := flags.Bool("notify", false, "send changes to the notification sinks in the environment")
+	formatName := formatFlag(flags)
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	format, err := parseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	store, err := openHistory(*historyFile)
+	if err != nil {
+		return err
+	}
+	if store != nil {
+		defer store.Close()
+	}
+
+	scraper, err := newScraper(ctx, nil, otpProvider())
+	if err != nil {
+		return err
+	}
+	defer closeScraper(scraper)
+
+	prices, err := scraper.GetPurchasePricesContext(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to get purchase prices: %w", err)
+		return err
+	}
+
+	err = output.Write(os.Stdout, format, output.Prices(prices))
+	if err != nil {
+		return err
+	}
+
+	if store == nil {
+		return nil
+	}
+
+	changes, err := store.RecordPrices(ctx, prices, time.Now())
+	if err != nil {
+		err = fmt.Errorf("failed to record prices: %w", err)
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	// changes are a report for people; keep them out of machine-readable output
+	w := io.Writer(os.Stdout)
+	if format == output.Table {
+		fmt.Println()
+	} else {
+		w = os.Stderr
+	}
+	notifier := notifier(w, *notifyChanges)
+	for _, change := range changes {
+		err = notifier.Notify(ctx, notify.PriceMessage(change.Price, change.Previous))
+		if err != nil {
+			err = fmt.Errorf("failed to send price change: %w", err)
+			return err
+		}
+	}
+
+	return nil
+}
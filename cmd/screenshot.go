@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+func runScreenshot(ctx context.Context, args []string) error {
+	flags := newFlagSet("screenshot", "[flags] <url>", "Log in if needed, open a member site page and save a screenshot of it.")
+	out := flags.String("out", "screenshot.png", "file to write the PNG to")
+	wait := flags.String("wait", "body", "selector that shows the page has loaded")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return usageError{"want exactly one url"}
+	}
+	url := flags.Arg(0)
+
+	scraper, err := newScraper(ctx, nil, otpProvider())
+	if err != nil {
+		return err
+	}
+	defer closeScraper(scraper)
+
+	err = scraper.AuthenticatedNavigateContext(ctx, url, *wait)
+	if err != nil {
+		err = fmt.Errorf("failed to visit %s: %w", url, err)
+		return err
+	}
+
+	err = scraper.Screenshot(*out)
+	if err != nil {
+		err = fmt.Errorf("failed to save screenshot: %w", err)
+		return err
+	}
+
+	fmt.Println("Saved", *out)
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/envy"
+	dvcscraper "github.com/lineleader/dvc-scraper"
+	"github.com/lineleader/dvc-scraper/metrics"
+	"github.com/lineleader/dvc-scraper/server"
+)
+
+func runServe(ctx context.Context, args []string) error {
+	flags := newFlagSet("serve", "[flags]", "Serve /prices, /availability, /stays, /session and /healthz as a JSON API until interrupted.")
+	addr := flags.String("addr", ":8080", "address to listen on")
+	apiKeys := flags.String("api-keys", envy.Get("API_KEYS", ""), "comma separated API keys clients must send; defaults to $API_KEYS")
+	noAuth := flags.Bool("no-auth", false, "serve without API keys")
+	timeout := flags.Duration("timeout", 2*time.Minute, "longest a request may take, including waiting for the browser")
+	withMetrics := flags.Bool("metrics", false, "serve Prometheus metrics on /metrics, without API key checks")
+	noOTP := flags.Bool("no-otp", false, "serve without $IMAP_ADDR; logins that ask for a passcode fail")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{}
+	for _, key := range strings.Split(*apiKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 && !*noAuth {
+		return usageError{"no API keys: set --api-keys or $API_KEYS, or pass --no-auth"}
+	}
+
+	// requests can't wait on a terminal, so passcodes must come from a mailbox
+	var otp dvcscraper.OTPProvider
+	if mailbox := imapOTP(); mailbox != nil {
+		otp = mailbox
+	} else if !*noOTP {
+		return usageError{"no passcode mailbox: set $IMAP_ADDR, $IMAP_USERNAME and $IMAP_PASSWORD, or pass --no-otp"}
+	}
+
+	var recorder *metrics.Metrics
+	var scraperMetrics dvcscraper.MetricsRecorder
+	if *withMetrics {
+		recorder = metrics.New()
+		scraperMetrics = recorder
+	}
+
+	scraper, err := newScraper(ctx, scraperMetrics, otp)
+	if err != nil {
+		return err
+	}
+	defer closeScraper(scraper)
+
+	handler, err := server.New(server.Options{
+		Scraper: scraper,
+		APIKeys: keys,
+		Timeout: *timeout,
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to create server: %w", err)
+		return err
+	}
+	defer handler.Close()
+	if recorder != nil {
+		handler.Handle("/metrics", recorder)
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Println("listening on", *addr)
+	err = srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
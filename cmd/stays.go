@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+	"github.com/lineleader/dvc-scraper/catalog"
+	"github.com/lineleader/dvc-scraper/output"
+)
+
+func runStays(ctx context.Context, args []string) error {
+	flags := newFlagSet("stays", "[flags]", "Find runs of consecutive bookable nights. Without --room every catalog room type at the chosen resorts is searched; room types the catalog doesn't list are searched when named with --resort and --room.")
+	resorts := flags.String("resort", "", "comma separated resort codes; defaults to all")
+	rooms := flags.String("room", "", "comma separated room type codes; defaults to all in the catalog")
+	from := flags.String("from", "", "first night as YYYY-MM-DD; defaults to today")
+	to := flags.String("to", "", "last night as YYYY-MM-DD; defaults to the end of the --from month")
+	minNights := flags.Int("min-nights", 1, "shortest stay")
+	maxNights := flags.Int("max-nights", 0, "longest stay; 0 for no limit")
+	maxPoints := flags.Int("max-points", 0, "most points for the whole stay; 0 for no limit")
+	minCapacity := flags.Int("min-capacity", 0, "skip room types sleeping fewer people")
+	split := flags.Bool("split", false, "include stays that move between room types once")
+	sortBy := flags.String("sort", "points", "order by 'points' or 'checkin'")
+	limit := flags.Int("limit", 20, "most stays to show; 0 for all")
+	formatName := formatFlag(flags)
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	format, err := parseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	start, end, err := dateFlags(*from, *to)
+	if err != nil {
+		return err
+	}
+
+	order := dvcscraper.SortByPoints
+	switch *sortBy {
+	case "points":
+	case "checkin":
+		order = dvcscraper.SortByCheckIn
+	default:
+		return usageError{fmt.Sprintf("invalid --sort '%s': want points or checkin", *sortBy)}
+	}
+
+	sweep, err := staySweep(listFlag(*resorts), listFlag(*rooms), *minCapacity)
+	if err != nil {
+		return err
+	}
+	sweep.Start, sweep.End = start, end
+
+	scraper, err := newScraper(ctx, nil, otpProvider())
+	if err != nil {
+		return err
+	}
+	defer closeScraper(scraper)
+
+	handle, err := scraper.NewAvailabilityHandleContext(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to get availability handle: %w", err)
+		return err
+	}
+	defer handle.Close()
+
+	all, err := handle.SweepAvailabilityContext(ctx, sweep)
+	var failures dvcscraper.SweepErrors
+	if errors.As(err, &failures) {
+		// one room type failing shouldn't hide stays in the rest
+		for _, failure := range failures {
+			log.Printf("failed to get availability for %s %s: %s", failure.RoomType.Resort, failure.RoomType.Code, failure.Err)
+		}
+		if len(all) == 0 {
+			return fmt.Errorf("failed to get availability for every room type: %w", err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	stays := []dvcscraper.Stay{}
+	if *split {
+		stays = dvcscraper.FindSplitStays(all, *minNights, *maxNights, *maxPoints)
+	} else {
+		for _, results := range all {
+			stays = append(stays, dvcscraper.FindStays(results, *minNights, *maxNights, *maxPoints)...)
+		}
+	}
+	dvcscraper.SortStays(stays, order)
+	if *limit > 0 && len(stays) > *limit {
+		stays = stays[:*limit]
+	}
+
+	return output.Write(os.Stdout, format, output.Stays(stays))
+}
+
+// staySweep returns the sweep over the room types matching the flags. Codes
+// the catalog doesn't list are searched anyway, with a warning.
+func staySweep(resorts, rooms []string, minCapacity int) (dvcscraper.SweepOptions, error) {
+	sweep := dvcscraper.SweepOptions{MinCapacity: minCapacity}
+	for _, resort := range resorts {
+		sweep.Resorts = append(sweep.Resorts, catalog.ResortCode(resort))
+	}
+	for _, room := range rooms {
+		sweep.RoomTypes = append(sweep.RoomTypes, catalog.RoomTypeCode(room))
+	}
+
+	roomTypes := sweep.MatchingRoomTypes()
+	if len(roomTypes) == 0 {
+		return sweep, usageError{"no catalog room types match --resort, --room and --min-capacity; name both --resort and --room to search other codes"}
+	}
+	for _, roomType := range roomTypes {
+		if _, ok := catalog.RoomTypeByCode(roomType.Resort, roomType.Code); !ok {
+			log.Printf("warning: %s %s isn't in the catalog; searching it anyway", roomType.Resort, roomType.Code)
+		}
+	}
+	return sweep, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStaySweep(t *testing.T) {
+	tests := []struct {
+		name        string
+		resorts     []string
+		rooms       []string
+		minCapacity int
+		want        []string
+		wantUsage   bool
+	}{
+		{name: "catalog", want: []string{"BLT 4O"}},
+		{name: "catalog resort", resorts: []string{"BLT"}, want: []string{"BLT 4O"}},
+		{name: "unlisted room type", resorts: []string{"BLT"}, rooms: []string{"4O", "1B"}, want: []string{"BLT 4O", "BLT 1B"}},
+		{name: "unlisted resort", resorts: []string{"VDH"}, rooms: []string{"DS"}, want: []string{"VDH DS"}},
+		{name: "unlisted resort without rooms", resorts: []string{"VDH"}, wantUsage: true},
+		{name: "too small", minCapacity: 8, wantUsage: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sweep, err := staySweep(tt.resorts, tt.rooms, tt.minCapacity)
+			var usage usageError
+			if tt.wantUsage {
+				if !errors.As(err, &usage) {
+					t.Fatalf("expected usage error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := []string{}
+			for _, roomType := range sweep.MatchingRoomTypes() {
+				got = append(got, string(roomType.Resort)+" "+string(roomType.Code))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
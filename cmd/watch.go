@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+	"github.com/lineleader/dvc-scraper/catalog"
+	"github.com/lineleader/dvc-scraper/notify"
+)
+
+func runWatch(ctx context.Context, args []string) error {
+	flags := newFlagSet("watch", "--resort CODE --room CODE [flags]", "Poll availability until interrupted and report nights that open, close or change.")
+	resort := flags.String("resort", "", "resort code, e.g. BLT (required)")
+	room := flags.String("room", "", "comma separated room type codes (required)")
+	from := flags.String("from", "", "first month to watch as YYYY-MM-DD; defaults to this month")
+	months := flags.Int("months", 1, "number of months to watch")
+	interval := flags.Duration("interval", 15*time.Minute, "time between polls")
+	snapshots := flags.String("snapshots", ".dvcscraper-watch.json", "file remembering the last results between runs; empty for memory only")
+	notifyChanges := flags.Bool("notify", false, "send changes to the notification sinks in the environment")
+	initial := flags.Bool("initial", false, "report every open night on the first poll")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if *resort == "" || *room == "" {
+		return usageError{"--resort and --room are required"}
+	}
+	if *months < 1 {
+		return usageError{"--months must be at least 1"}
+	}
+	start, _, err := dateFlags(*from, "")
+	if err != nil {
+		return err
+	}
+
+	requests := []dvcscraper.AvailabilityOptions{}
+	for _, code := range listFlag(*room) {
+		for i := 0; i < *months; i++ {
+			y, m, _ := start.Date()
+			opts := dvcscraper.AvailabilityOptions{
+				Resort:   catalog.ResortCode(strings.ToUpper(*resort)),
+				RoomType: catalog.RoomTypeCode(code),
+				Date:     time.Date(y, m+time.Month(i), 1, 0, 0, 0, 0, start.Location()),
+			}
+			if i == 0 {
+				opts.Date = start
+			}
+			err = opts.Validate()
+			if err != nil {
+				return usageError{err.Error()}
+			}
+			requests = append(requests, opts)
+		}
+	}
+
+	scraper, err := newScraper(ctx, nil, otpProvider())
+	if err != nil {
+		return err
+	}
+	defer closeScraper(scraper)
+
+	handle, err := scraper.NewAvailabilityHandleContext(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to get availability handle: %w", err)
+		return err
+	}
+	defer handle.Close()
+
+	watchOpts := dvcscraper.WatcherOptions{
+		Requests:    requests,
+		Interval:    *interval,
+		EmitInitial: *initial,
+	}
+	if *snapshots != "" {
+		watchOpts.Snapshots = dvcscraper.FileSnapshotStore{Path: *snapshots}
+	}
+
+	watcher, err := dvcscraper.NewWatcher(handle, watchOpts)
+	if err != nil {
+		err = fmt.Errorf("failed to start watcher: %w", err)
+		return err
+	}
+
+	notifier := notifier(os.Stdout, *notifyChanges)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range watcher.Events() {
+			err := notifier.Notify(context.Background(), notify.AvailabilityMessage(event))
+			if err != nil {
+				log.Println("failed to send availability change:", err)
+			}
+		}
+	}()
+
+	err = watcher.Run(ctx)
+	<-done
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,209 @@
+package dvcscraper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+const (
+	defaultEmailVar       = "EMAIL"
+	defaultPasswordVar    = "PASSWORD"
+	defaultKeyringService = "dvc-scraper"
+)
+
+// Credentials are the email and password used to sign in
+type Credentials struct {
+	Email    string
+	Password string
+}
+
+// CredentialProvider supplies Credentials. Scrapers only ask for them when a
+// login is actually needed, so a valid saved session never touches the secret.
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// CredentialProviderFunc adapts a function to a CredentialProvider
+type CredentialProviderFunc func(ctx context.Context) (Credentials, error)
+
+// Credentials calls f
+func (f CredentialProviderFunc) Credentials(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// StaticCredentials returns a CredentialProvider for fixed credentials
+func StaticCredentials(email, password string) CredentialProvider {
+	return CredentialProviderFunc(func(context.Context) (Credentials, error) {
+		return Credentials{Email: email, Password: password}, nil
+	})
+}
+
+// EnvCredentials reads credentials from environment variables
+type EnvCredentials struct {
+	// EmailVar defaults to EMAIL
+	EmailVar string
+	// PasswordVar defaults to PASSWORD
+	PasswordVar string
+}
+
+// Credentials reads the environment variables
+func (e EnvCredentials) Credentials(context.Context) (Credentials, error) {
+	emailVar := e.EmailVar
+	if emailVar == "" {
+		emailVar = defaultEmailVar
+	}
+	passwordVar := e.PasswordVar
+	if passwordVar == "" {
+		passwordVar = defaultPasswordVar
+	}
+
+	creds := Credentials{
+		Email:    os.Getenv(emailVar),
+		Password: os.Getenv(passwordVar),
+	}
+	if creds.Email == "" || creds.Password == "" {
+		return creds, fmt.Errorf("credentials missing from %s and %s", emailVar, passwordVar)
+	}
+
+	return creds, nil
+}
+
+// FileCredentials reads credentials from a dotenv style file with EMAIL and
+// PASSWORD entries
+type FileCredentials struct {
+	Path string
+}
+
+// Credentials reads the file
+func (f FileCredentials) Credentials(context.Context) (Credentials, error) {
+	creds := Credentials{}
+
+	file, err := os.Open(f.Path)
+	if err != nil {
+		err = fmt.Errorf("failed to open credentials file: %w", err)
+		return creds, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+
+		switch strings.TrimSpace(parts[0]) {
+		case defaultEmailVar:
+			creds.Email = value
+		case defaultPasswordVar:
+			creds.Password = value
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to read credentials file: %w", err)
+		return creds, err
+	}
+
+	if creds.Email == "" || creds.Password == "" {
+		return creds, fmt.Errorf("credentials file '%s' is missing %s or %s", f.Path, defaultEmailVar, defaultPasswordVar)
+	}
+
+	return creds, nil
+}
+
+// CommandCredentials runs a password manager command, like `pass show dvc`
+// or `op read op://vault/dvc/password`, and uses the first line of its
+// output as the password.
+//
+// If Email is empty it's taken from a later "login:", "email:" or
+// "username:" line, following the pass convention.
+type CommandCredentials struct {
+	Email   string
+	Command []string
+}
+
+// Credentials runs the command
+func (c CommandCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	creds := Credentials{Email: c.Email}
+
+	if len(c.Command) == 0 {
+		return creds, errors.New("no credentials command given")
+	}
+
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		err = fmt.Errorf("failed to run credentials command: %w: %s", err, strings.TrimSpace(stderr.String()))
+		return creds, err
+	}
+
+	lines := strings.Split(strings.TrimRight(stdout.String(), "\r\n"), "\n")
+	creds.Password = strings.TrimRight(lines[0], "\r")
+
+	for _, line := range lines[1:] {
+		if creds.Email != "" {
+			break
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(parts[0])) {
+		case "login", "email", "username":
+			creds.Email = strings.TrimSpace(parts[1])
+		}
+	}
+
+	if creds.Email == "" || creds.Password == "" {
+		return creds, errors.New("credentials command did not provide an email and password")
+	}
+
+	return creds, nil
+}
+
+// KeyringCredentials reads the password from the OS keyring: the Secret
+// Service on Linux, Keychain on macOS and Credential Manager on Windows
+type KeyringCredentials struct {
+	// Service defaults to dvc-scraper
+	Service string
+	Email   string
+}
+
+// Credentials looks up the password for Email
+func (k KeyringCredentials) Credentials(context.Context) (Credentials, error) {
+	creds := Credentials{Email: k.Email}
+
+	service := k.Service
+	if service == "" {
+		service = defaultKeyringService
+	}
+
+	password, err := keyring.Get(service, k.Email)
+	if err != nil {
+		err = fmt.Errorf("failed to get password from keyring: %w", err)
+		return creds, err
+	}
+	creds.Password = password
+
+	return creds, nil
+}
@@ -0,0 +1,192 @@
+package dvcscraper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+// setenv sets key for the rest of the test
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+
+	old, had := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if had {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestEnvCredentials(t *testing.T) {
+	setenv(t, "EMAIL", "member@example.com")
+	setenv(t, "PASSWORD", "hunter2")
+	setenv(t, "DVC_EMAIL", "other@example.com")
+	setenv(t, "DVC_PASSWORD", "")
+
+	tests := []struct {
+		name    string
+		env     EnvCredentials
+		want    Credentials
+		wantErr string
+	}{
+		{name: "defaults", env: EnvCredentials{}, want: Credentials{Email: "member@example.com", Password: "hunter2"}},
+		{name: "custom email var", env: EnvCredentials{EmailVar: "DVC_EMAIL"}, want: Credentials{Email: "other@example.com", Password: "hunter2"}},
+		{name: "empty password", env: EnvCredentials{PasswordVar: "DVC_PASSWORD"}, wantErr: "EMAIL and DVC_PASSWORD"},
+		{name: "unset var", env: EnvCredentials{EmailVar: "DVC_SCRAPER_TEST_UNSET"}, wantErr: "DVC_SCRAPER_TEST_UNSET"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.env.Credentials(context.Background())
+			checkCredentials(t, got, err, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		err := os.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    Credentials
+		wantErr string
+	}{
+		{
+			name: "plain",
+			path: write("plain.env", "EMAIL=member@example.com\nPASSWORD=hunter2\n"),
+			want: Credentials{Email: "member@example.com", Password: "hunter2"},
+		},
+		{
+			name: "quotes comments and spacing",
+			path: write("quoted.env", "# dvc login\n\n EMAIL = \"member@example.com\"\nOTHER=x\nPASSWORD='pa=ss word'\nnot a pair\n"),
+			want: Credentials{Email: "member@example.com", Password: "pa=ss word"},
+		},
+		{
+			name:    "missing password",
+			path:    write("partial.env", "EMAIL=member@example.com\n"),
+			wantErr: "missing EMAIL or PASSWORD",
+		},
+		{
+			name:    "missing file",
+			path:    filepath.Join(dir, "nope.env"),
+			wantErr: "failed to open credentials file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FileCredentials{Path: tt.path}.Credentials(context.Background())
+			checkCredentials(t, got, err, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func TestCommandCredentials(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	tests := []struct {
+		name    string
+		cmd     CommandCredentials
+		want    Credentials
+		wantErr string
+	}{
+		{
+			name: "password only",
+			cmd:  CommandCredentials{Email: "member@example.com", Command: []string{"sh", "-c", `printf 'hunter2\n'`}},
+			want: Credentials{Email: "member@example.com", Password: "hunter2"},
+		},
+		{
+			name: "pass style login line",
+			cmd:  CommandCredentials{Command: []string{"sh", "-c", `printf 'hunter2\r\nurl: disney.go.com\nLogin: member@example.com\nemail: other@example.com\n'`}},
+			want: Credentials{Email: "member@example.com", Password: "hunter2"},
+		},
+		{
+			name: "given email wins",
+			cmd:  CommandCredentials{Email: "member@example.com", Command: []string{"sh", "-c", `printf 'hunter2\nemail: other@example.com\n'`}},
+			want: Credentials{Email: "member@example.com", Password: "hunter2"},
+		},
+		{
+			name:    "no email",
+			cmd:     CommandCredentials{Command: []string{"sh", "-c", `printf 'hunter2\n'`}},
+			wantErr: "did not provide an email and password",
+		},
+		{
+			name:    "command fails",
+			cmd:     CommandCredentials{Email: "member@example.com", Command: []string{"sh", "-c", `echo locked >&2; exit 1`}},
+			wantErr: "locked",
+		},
+		{
+			name:    "no command",
+			cmd:     CommandCredentials{Email: "member@example.com"},
+			wantErr: "no credentials command",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cmd.Credentials(context.Background())
+			checkCredentials(t, got, err, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func TestKeyringCredentials(t *testing.T) {
+	keyring.MockInit()
+	_ = keyring.Set("dvc-scraper", "member@example.com", "hunter2")
+	_ = keyring.Set("work", "member@example.com", "correct horse")
+
+	tests := []struct {
+		name    string
+		keyring KeyringCredentials
+		want    Credentials
+		wantErr string
+	}{
+		{name: "default service", keyring: KeyringCredentials{Email: "member@example.com"}, want: Credentials{Email: "member@example.com", Password: "hunter2"}},
+		{name: "custom service", keyring: KeyringCredentials{Service: "work", Email: "member@example.com"}, want: Credentials{Email: "member@example.com", Password: "correct horse"}},
+		{name: "unknown email", keyring: KeyringCredentials{Email: "other@example.com"}, wantErr: "failed to get password from keyring"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.keyring.Credentials(context.Background())
+			checkCredentials(t, got, err, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func checkCredentials(t *testing.T, got Credentials, err error, want Credentials, wantErr string) {
+	t.Helper()
+
+	if wantErr != "" {
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Fatalf("expected error containing %q, got %v", wantErr, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
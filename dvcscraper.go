@@ -9,11 +9,10 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"os"
+	"sync"
 	"time"
 
 	"github.com/go-rod/rod"
-	"github.com/go-rod/rod/lib/launcher"
 	"github.com/go-rod/rod/lib/proto"
 	"github.com/go-rod/stealth"
 )
@@ -23,97 +22,304 @@ const (
 )
 
 type ScraperOptions struct {
+	// Credentials supplies the login email and password when a login is
+	// needed. Email and Password are used when it's nil.
+	Credentials CredentialProvider
+
 	Email    string
 	Password string
 
 	Logger *log.Logger
 
+	// OTPProvider supplies one-time passcodes when login asks for one
+	OTPProvider OTPProvider
+	// OTPTimeout is how long a login waits for OTPProvider. Defaults to 5
+	// minutes.
+	OTPTimeout time.Duration
+
+	// RetryPolicy retries failed navigations, availability and price
+	// requests. The zero value doesn't retry.
+	RetryPolicy RetryPolicy
+
+	// RateLimits override DefaultRateLimits per endpoint class
+	RateLimits map[EndpointClass]RateLimit
+
+	// SessionStore persists the browser session between runs. Defaults to a
+	// file named .dvcscraper-session.json in the working directory.
+	SessionStore SessionStore
+	// SessionKeys encrypts the saved session at rest. The first key encrypts;
+	// the others are only used to read sessions saved before a key rotation.
+	SessionKeys []SessionKey
+	// RequireEncryptedSession refuses to load a plaintext saved session
+	RequireEncryptedSession bool
+
+	// Metrics receives logins, request latencies, retries and results.
+	// Defaults to discarding them.
+	Metrics MetricsRecorder
+
+	// PagePoolSize is how many browser pages operations may use at once.
+	// Each open AvailabilityHandle holds one until it's closed. Defaults to 4.
+	PagePoolSize int
+
+	// ProfileDir runs the browser with a persistent profile in this directory
+	// so local storage and IndexedDB survive between runs too, not just
+	// cookies. Use one directory per account; it is locked while the Scraper
+	// is open. On first use the session saved in SessionStore is imported;
+	// afterwards only session-only cookies, which the browser drops on exit,
+	// are restored from it. It overrides Browser.UserDataDir and can't be used
+	// with Browser.RemoteURL.
+	ProfileDir string
+
+	// Browser controls how the browser is launched or connected to and how
+	// its pages are emulated
+	Browser BrowserOptions
+
 	SkipSession bool
 	BinaryPath  string
 	MonitorURL  string
 }
 
-// Scraper provides authenticated access to the DVC website to scrape data easily
+// Scraper provides authenticated access to the DVC website to scrape data easily.
+//
+// A Scraper is safe for concurrent use. Each operation checks out its own
+// page from a pool shared by the browser's session, waiting when all of them
+// are busy. AuthenticatedNavigate and Screenshot share one primary page so a
+// screenshot shows the last page navigated to.
 type Scraper struct {
-	email    string
-	password string
-
-	logger *log.Logger
-
-	browser *rod.Browser
-	page    *rod.Page
+	credentials CredentialProvider
+
+	logger     *log.Logger
+	otp        OTPProvider
+	otpTimeout time.Duration
+	retry      RetryPolicy
+
+	limiter *rateLimiter
+	metrics MetricsRecorder
+
+	sessions SessionStore
+	// saveSession is false until the saved session has been restored, so
+	// closing a Scraper that failed to start doesn't overwrite a session it
+	// couldn't read
+	saveSession bool
+
+	browser     *rod.Browser
+	browserOpts BrowserOptions
+	// keepBrowser leaves the browser running on Close, for remote browsers
+	keepBrowser bool
+	// profile is held while a persistent profile is in use
+	profile *profileLock
+
+	pages *pagePool
+	login *loginState
+
+	// primaryMu guards page, the primary page
+	primaryMu *sync.Mutex
+	page      *rod.Page
 }
 
 type elementable interface {
 	Element(string) (*rod.Element, error)
+	GetContext() context.Context
+}
+
+// selectorError explains a failed Element lookup of what on page. Element
+// waits without a timeout of its own, so if page's context is done the lookup
+// was cancelled and that error is returned as-is rather than as a missing
+// selector.
+func selectorError(page elementable, what, selector string, err error) error {
+	if ctxErr := page.GetContext().Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return fmt.Errorf("failed to get %s: %w", what, &SelectorError{Selector: selector, Err: err})
 }
 
 // New returns a Scraper ready to roll
 func New(opts ScraperOptions) (Scraper, error) {
-	scraper := Scraper{
-		email:    opts.Email,
-		password: opts.Password,
+	var profile *profileLock
+	if opts.ProfileDir != "" {
+		if opts.Browser.remoteURL() != "" {
+			return Scraper{logger: log.Default()}, errors.New("a profile directory can't be used with a remote browser")
+		}
 
-		logger: log.Default(),
+		var err error
+		profile, err = lockProfile(opts.ProfileDir)
+		if err != nil {
+			return Scraper{logger: log.Default()}, profileError(opts.ProfileDir, err)
+		}
+		opts.Browser.UserDataDir = opts.ProfileDir
 	}
 
-	if opts.Logger != nil {
-		scraper.logger = opts.Logger
+	browser, err := connectBrowser(opts)
+	if err != nil {
+		_ = profile.release()
+		return Scraper{logger: log.Default()}, err
+	}
+
+	return newWithProfile(browser, profile, opts)
+}
+
+// NewContext is like New but gives up waiting for the browser to start when
+// ctx is done. ctx does not limit the lifetime of the returned Scraper.
+func NewContext(ctx context.Context, opts ScraperOptions) (Scraper, error) {
+	type started struct {
+		scraper Scraper
+		err     error
 	}
 
-	scraper.browser = rod.New()
+	done := make(chan started, 1)
+	go func() {
+		scraper, err := New(opts)
+		done <- started{scraper: scraper, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.scraper, res.err
+	case <-ctx.Done():
+		go func() {
+			res := <-done
+			if res.scraper.browser != nil {
+				res.scraper.Close()
+			}
+		}()
+		return Scraper{logger: log.Default()}, ctx.Err()
+	}
+}
 
-	if opts.BinaryPath != "" {
-		u, err := launcher.New().Bin(opts.BinaryPath).Launch()
+// connectBrowser launches or connects to the browser described by opts
+func connectBrowser(opts ScraperOptions) (*rod.Browser, error) {
+	browser := rod.New()
+
+	if opts.Browser.remoteURL() != "" {
+		u, err := opts.Browser.controlURL()
 		if err != nil {
-			err = fmt.Errorf("failed to : %w", err)
-			return scraper, err
+			return browser, startingError{
+				msg:           fmt.Sprintf("failed to resolve remote browser at '%s': %s", opts.Browser.remoteURL(), err.Error()),
+				failedToStart: true,
+				err:           err,
+			}
 		}
 
-		scraper.browser.ControlURL(u)
+		browser.ControlURL(u)
+	} else {
+		u, err := opts.Browser.launcher(opts.BinaryPath).Launch()
+		if err != nil {
+			return browser, startingError{
+				msg:           fmt.Sprintf("failed to launch browser at '%s': %s", opts.BinaryPath, err.Error()),
+				failedToStart: true,
+				err:           err,
+			}
+		}
+
+		browser.ControlURL(u)
 	}
 
 	if opts.MonitorURL != "" {
-		scraper.browser.ServeMonitor(opts.MonitorURL)
+		browser.ServeMonitor(opts.MonitorURL)
 	}
 
-	err := scraper.browser.Connect()
+	err := browser.Connect()
 	if err != nil {
-		err = fmt.Errorf("failed to connect to browser: %w", err)
-		return scraper, err
+		return browser, startingError{
+			msg:           fmt.Sprintf("failed to connect to browser: %s", err.Error()),
+			failedToStart: true,
+			err:           err,
+		}
+	}
+
+	return browser, nil
+}
+
+// newWithBrowser returns a Scraper driving an already connected browser
+func newWithBrowser(browser *rod.Browser, opts ScraperOptions) (Scraper, error) {
+	return newWithProfile(browser, nil, opts)
+}
+
+// newWithProfile is newWithBrowser for a browser launched with the locked
+// profile, which the Scraper releases on Close. profile may be nil.
+func newWithProfile(browser *rod.Browser, profile *profileLock, opts ScraperOptions) (Scraper, error) {
+	scraper := Scraper{
+		credentials: opts.Credentials,
+
+		logger:     log.Default(),
+		otp:        opts.OTPProvider,
+		otpTimeout: defaultOTPTimeout,
+		retry:      opts.RetryPolicy,
+		limiter:    newRateLimiter(opts.RateLimits),
+		metrics:    nopMetrics{},
+		sessions:   NewFileSessionStore(cookieSessionFile),
+
+		browser:     browser,
+		browserOpts: opts.Browser,
+		keepBrowser: opts.Browser.remoteURL() != "",
+		profile:     profile,
+		pages:       newPagePool(browser, opts.PagePoolSize, opts.Browser.preparePage),
+		login:       &loginState{},
+		primaryMu:   &sync.Mutex{},
+	}
+
+	if opts.Logger != nil {
+		scraper.logger = opts.Logger
+	}
+
+	if opts.OTPTimeout > 0 {
+		scraper.otpTimeout = opts.OTPTimeout
+	}
+
+	if opts.Metrics != nil {
+		scraper.metrics = opts.Metrics
+	}
+
+	if scraper.credentials == nil {
+		scraper.credentials = StaticCredentials(opts.Email, opts.Password)
+	}
+
+	if opts.SessionStore != nil {
+		scraper.sessions = opts.SessionStore
+	}
+
+	if len(opts.SessionKeys) > 0 {
+		store, err := NewEncryptedSessionStore(scraper.sessions, opts.SessionKeys...)
+		if err != nil {
+			err = fmt.Errorf("failed to set up session encryption: %w", err)
+			return scraper, err
+		}
+		store.RequireEncryption = opts.RequireEncryptedSession
+		scraper.sessions = store
+	} else if opts.RequireEncryptedSession {
+		return scraper, errors.New("encrypted sessions are required but no session keys were given")
 	}
 
-	if !opts.SkipSession {
+	var err error
+	if !opts.SkipSession && profile != nil {
+		err = scraper.importSession()
+		if err != nil {
+			err = fmt.Errorf("failed to import session into profile: %w", err)
+		}
+	} else if !opts.SkipSession {
 		err = scraper.readCookies()
 		if err != nil {
 			err = fmt.Errorf("failed to read cookies: %w", err)
 		}
 	}
+	scraper.saveSession = err == nil
 
 	return scraper, err
 }
 
 func (s *Scraper) readCookies() error {
-	_, err := os.Stat(cookieSessionFile)
-	if os.IsNotExist(err) {
+	raw, err := s.sessions.Load()
+	if errors.Is(err, ErrNoSession) {
 		// no previous session; continue
 		return nil
 	} else if err != nil {
-		err = fmt.Errorf("failed to check for session file: %w", err)
+		err = fmt.Errorf("failed to load session: %w", err)
 		return err
 	}
 
-	cookieReader, err := os.Open(cookieSessionFile)
+	err = s.SetCookies(bytes.NewReader(raw))
 	if err != nil {
-		err = fmt.Errorf("failed to read cookie session file: %w", err)
-		return err
-	}
-	defer cookieReader.Close()
-
-	err = s.SetCookies(cookieReader)
-	if err != nil {
-		err = fmt.Errorf("failed to set cookies: %w", err)
-		return err
+		return &SessionCorruptError{Reason: "failed to set cookies", Err: err}
 	}
 
 	return nil
@@ -167,23 +373,52 @@ func (s *Scraper) SetCookies(raw io.Reader) error {
 	return nil
 }
 
-// Close cleans up resources for the Scraper
+// Close saves the browser's cookies to the SessionStore and cleans up
+// resources for the Scraper. A persistent profile keeps everything but
+// session-only cookies itself; the saved copy restores those next time. It's
+// safe to call on the Scraper returned alongside an error from New, and then
+// leaves the saved session as it was.
 func (s *Scraper) Close() error {
+	if s.browser == nil {
+		// the browser never started
+		return s.profile.release()
+	}
+
 	err := s.cleanup()
 	if err != nil {
 		s.logger.Println(err.Error())
 	}
 
+	if s.profile != nil {
+		err = s.browser.Close()
+		if err != nil {
+			err = fmt.Errorf("failed to close browser: %w", err)
+			s.logger.Println(err.Error())
+		}
+
+		return s.profile.release()
+	}
+
+	if s.keepBrowser {
+		// the remote browser outlives us; only close the pages we opened
+		s.primaryMu.Lock()
+		if s.page != nil {
+			_ = s.page.Close()
+			s.page = nil
+		}
+		s.primaryMu.Unlock()
+
+		s.pages.close()
+		return nil
+	}
+
 	return s.browser.Close()
 }
 
 func (s *Scraper) cleanup() error {
-	cookieWriter, err := os.Create(cookieSessionFile)
-	if err != nil {
-		err = fmt.Errorf("failed to open session cookie file: %w", err)
-		return err
+	if !s.saveSession {
+		return nil
 	}
-	defer cookieWriter.Close()
 
 	cookieBytes, err := s.GetCookies()
 	if err != nil {
@@ -191,12 +426,42 @@ func (s *Scraper) cleanup() error {
 		return err
 	}
 
-	_, err = cookieWriter.Write(cookieBytes)
-	return err
+	err = s.sessions.Save(cookieBytes)
+	if err != nil {
+		err = fmt.Errorf("failed to save session: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// ClearSession deletes the saved session and the browser's cookies so the
+// next login starts from scratch
+func (s *Scraper) ClearSession() error {
+	if s.profile != nil {
+		// before the cookies go, since their domains name origins to clear
+		err := s.clearSiteData()
+		if err != nil {
+			return err
+		}
+	}
+
+	err := s.browser.SetCookies(nil)
+	if err != nil {
+		err = fmt.Errorf("failed to clear browser cookies: %w", err)
+		return err
+	}
+
+	return s.sessions.Delete()
 }
 
+// Screenshot saves the primary page, the one AuthenticatedNavigate uses, as a
+// PNG
 func (s *Scraper) Screenshot(filename string) error {
-	page, err := s.getPage()
+	s.primaryMu.Lock()
+	defer s.primaryMu.Unlock()
+
+	page, err := s.primaryPage()
 	if err != nil {
 		err = fmt.Errorf("failed to get page for screenshot: %w", err)
 		return err
@@ -206,10 +471,40 @@ func (s *Scraper) Screenshot(filename string) error {
 	return nil
 }
 
+// AuthenticatedNavigate visits url, logging in first if successSelector
+// doesn't show up because the session has expired
 func (s *Scraper) AuthenticatedNavigate(url, successSelector string) error {
-	page, err := s.getPage()
+	return s.AuthenticatedNavigateContext(context.Background(), url, successSelector)
+}
+
+// AuthenticatedNavigateContext is AuthenticatedNavigate bounded by ctx. It
+// navigates the primary page; concurrent calls take turns.
+func (s *Scraper) AuthenticatedNavigateContext(ctx context.Context, url, successSelector string) error {
+	s.primaryMu.Lock()
+	defer s.primaryMu.Unlock()
+
+	start := time.Now()
+	page, err := s.primaryPage()
 	if err != nil {
 		err = fmt.Errorf("failed to get page for navigation: %w", err)
+		s.observe(endpointNavigation, start, err)
+		return err
+	}
+
+	err = s.withRetry(ctx, endpointNavigation, "navigation to "+url, s.reauthOn(page), func() error {
+		return s.authenticatedNavigate(ctx, page, url, successSelector)
+	})
+	s.observe(endpointNavigation, start, err)
+	return err
+}
+
+// authenticatedNavigate visits url on page, logging in on the same page if
+// the session has expired
+func (s *Scraper) authenticatedNavigate(ctx context.Context, page *rod.Page, url, successSelector string) error {
+	page = page.Context(ctx)
+
+	err := s.waitRateLimit(ctx, EndpointNavigation)
+	if err != nil {
 		return err
 	}
 
@@ -243,19 +538,26 @@ func (s *Scraper) AuthenticatedNavigate(url, successSelector string) error {
 		return err
 	}
 
+	var loginErr error
 	if notLoggedIn {
 		s.logger.Println("Need to re-auth")
-		err = s.Login()
-		if err != nil {
-			if isCertainlyLoginError(err) {
-				return err
+		s.metrics.Reauth()
+		loginErr = s.loginOn(ctx, page)
+		if loginErr != nil {
+			if isCertainlyLoginError(loginErr) {
+				return loginErr
 			}
-			s.logger.Println("Possible login error: %w", err)
+			s.logger.Println("Possible login error:", loginErr)
 		}
 		s.logger.Println("Finished re-auth")
 	}
 
 	s.logger.Println("Navigating to original URL:", url)
+	err = s.waitRateLimit(ctx, EndpointNavigation)
+	if err != nil {
+		return err
+	}
+
 	wait = waitNavigation(page)
 	err = page.Navigate(url)
 	if err != nil {
@@ -264,14 +566,45 @@ func (s *Scraper) AuthenticatedNavigate(url, successSelector string) error {
 	}
 	wait()
 
+	if loginErr != nil {
+		signedOut, err := showsSignIn(page, successSelector)
+		if err != nil {
+			err = fmt.Errorf("failed to check for sign in form after login: %w", err)
+			return err
+		}
+		if signedOut {
+			err = fmt.Errorf("still signed out after login (%s): %w", loginErr.Error(), ErrSessionExpired)
+			return err
+		}
+	}
+
 	return nil
 }
 
+// showsSignIn reports whether page shows the sign in iframe rather than
+// successSelector. It waits up to signinSuccessTimeout for either and reports
+// false when neither shows up.
+func showsSignIn(page *rod.Page, successSelector string) (bool, error) {
+	signedOut := false
+	_, err := page.Timeout(signinSuccessTimeout).Race().
+		Element(successSelector).
+		Element(signInIFrameSelector).Handle(func(*rod.Element) error {
+		signedOut = true
+		return nil
+	}).Do()
+	if errors.Is(err, context.DeadlineExceeded) && page.GetContext().Err() == nil {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return signedOut, nil
+}
+
 func (s *Scraper) click(page *rod.Page, selector string) error {
 	clickElem, err := page.Element(selector)
 	if err != nil {
-		err = fmt.Errorf("failed to get element for click: %w", err)
-		return err
+		return selectorError(page, "element for click", selector, err)
 	}
 	s.logger.Printf("got '%s' element to click", selector)
 
@@ -281,8 +614,7 @@ func (s *Scraper) click(page *rod.Page, selector string) error {
 func typeInput(page *rod.Page, selector, text string) error {
 	inputElem, err := page.Element(selector)
 	if err != nil {
-		err = fmt.Errorf("failed to get element for input: %w", err)
-		return err
+		return selectorError(page, "element for input", selector, err)
 	}
 
 	return inputElem.Input(text)
@@ -291,8 +623,7 @@ func typeInput(page *rod.Page, selector, text string) error {
 func getIFrame(page *rod.Page, selector string) (*rod.Page, error) {
 	frameElem, err := page.Element(selector)
 	if err != nil {
-		err = fmt.Errorf("failed to get iframe element: %w", err)
-		return nil, err
+		return nil, selectorError(page, "iframe element", selector, err)
 	}
 
 	frame, err := frameElem.Frame()
@@ -304,23 +635,40 @@ func getIFrame(page *rod.Page, selector string) (*rod.Page, error) {
 	return frame, nil
 }
 
-func (s *Scraper) getPage() (*rod.Page, error) {
+// primaryPage returns the primary page, opening it on first use. Call with
+// primaryMu held.
+func (s *Scraper) primaryPage() (*rod.Page, error) {
 	var err error
 	if s.page == nil {
-		s.page, err = stealth.Page(s.browser)
+		var page *rod.Page
+		page, err = stealth.Page(s.browser)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.browserOpts.preparePage(page)
 		if err != nil {
-			return s.page, err
+			_ = page.Close()
+			return nil, err
 		}
+		s.page = page
 	}
 
 	return s.page, nil
 }
 
+// reauthOn returns a withRetry reauth func that logs in on page, so a retry
+// doesn't need a second page from the pool
+func (s *Scraper) reauthOn(page *rod.Page) func(context.Context) error {
+	return func(ctx context.Context) error {
+		return s.loginOn(ctx, page)
+	}
+}
+
 func textOfElement(page elementable, selector string) (string, error) {
 	elem, err := page.Element(selector)
 	if err != nil {
-		err = fmt.Errorf("failed to get element for text: %w", err)
-		return "", err
+		return "", selectorError(page, "element for text", selector, err)
 	}
 
 	return elem.Text()
@@ -330,7 +678,7 @@ func onPage(page *rod.Page, selector string) (bool, error) {
 	err := rod.Try(func() {
 		page.Timeout(10 * time.Second).MustElement(selector)
 	})
-	if errors.Is(err, context.DeadlineExceeded) {
+	if errors.Is(err, context.DeadlineExceeded) && page.GetContext().Err() == nil {
 		return false, nil
 	} else if err != nil {
 		return false, err
@@ -350,6 +698,8 @@ func isCertainlyLoginError(err error) bool {
 	return ok && ce.CertainlyFailed()
 }
 
+// FailedToStart reports whether err came from the browser failing to launch
+// or connect. Such errors also match ErrBrowserLaunch.
 func FailedToStart(err error) bool {
 	type failed interface {
 		FailedToStart() bool
@@ -361,7 +711,12 @@ func FailedToStart(err error) bool {
 type startingError struct {
 	msg           string
 	failedToStart bool
+	err           error
 }
 
 func (s startingError) Error() string       { return s.msg }
 func (s startingError) FailedToStart() bool { return s.failedToStart }
+func (s startingError) Unwrap() error       { return s.err }
+func (s startingError) Is(target error) bool {
+	return target == ErrBrowserLaunch && s.failedToStart
+}
@@ -0,0 +1,23 @@
+package dvcscraper
+
+import (
+	"testing"
+)
+
+func TestCleanupKeepsUnrestoredSession(t *testing.T) {
+	store := NewMemorySessionStore()
+	_ = store.Save([]byte("undecryptable"))
+
+	// a Scraper whose session couldn't be restored must not save over it;
+	// with saveSession unset cleanup returns before touching the browser
+	s := Scraper{sessions: store}
+	err := s.cleanup()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	raw, _ := store.Load()
+	if string(raw) != "undecryptable" {
+		t.Fatalf("saved session overwritten with %q", raw)
+	}
+}
@@ -0,0 +1,78 @@
+package dvcscraper
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Kinds of scraper failure. Errors returned by the Scraper wrap one of these
+// where the kind is known, so callers can branch with errors.Is.
+var (
+	// ErrLoginRejected means the site refused the credentials or passcode
+	ErrLoginRejected = errors.New("login rejected")
+	// ErrSessionExpired means the site no longer accepts the session: an API
+	// request was refused, or a page still showed the sign in form after
+	// logging in again
+	ErrSessionExpired = errors.New("session expired")
+	// ErrSelectorNotFound means an expected element wasn't on the page. Use
+	// errors.As with *SelectorError to get the selector.
+	ErrSelectorNotFound = errors.New("selector not found")
+	// ErrSiteChanged means the site responded in a shape the scraper doesn't
+	// understand
+	ErrSiteChanged = errors.New("site changed")
+	// ErrRateLimited means the site asked the scraper to slow down
+	ErrRateLimited = errors.New("rate limited")
+	// ErrAPIResponse means an API request got an unsuccessful response. Use
+	// errors.As with *APIError to get the status and body.
+	ErrAPIResponse = errors.New("unexpected API response")
+	// ErrBrowserLaunch means the browser couldn't be started or reached
+	ErrBrowserLaunch = errors.New("failed to launch browser")
+	// ErrProfileLocked means another process has the browser profile open.
+	// It also matches ErrBrowserLaunch.
+	ErrProfileLocked = errors.New("browser profile is locked")
+)
+
+// SelectorError is returned when an element can't be found on a page
+type SelectorError struct {
+	Selector string
+	Err      error
+}
+
+func (e *SelectorError) Error() string {
+	return fmt.Sprintf("failed to find '%s': %s", e.Selector, e.Err.Error())
+}
+
+func (e *SelectorError) Unwrap() error { return e.Err }
+
+// Is makes a SelectorError match ErrSelectorNotFound
+func (e *SelectorError) Is(target error) bool { return target == ErrSelectorNotFound }
+
+// APIError is returned when an API request made from the page gets an
+// unsuccessful response
+type APIError struct {
+	URL    string
+	Status int
+	Body   string
+	// RetryAfter is how long the site asked to wait, for rate limited responses
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("unexpected response %d from %s: %s", e.Status, e.URL, e.Body)
+}
+
+// Is makes an APIError match ErrAPIResponse, and ErrRateLimited or
+// ErrSessionExpired depending on its status
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrAPIResponse:
+		return true
+	case ErrRateLimited:
+		return e.Status == http.StatusTooManyRequests
+	case ErrSessionExpired:
+		return e.Status == http.StatusUnauthorized || e.Status == http.StatusForbidden
+	}
+	return false
+}
@@ -1,10 +1,13 @@
 module github.com/lineleader/dvc-scraper
 
-go 1.15
+go 1.16
 
 require (
+	github.com/emersion/go-imap v1.2.1
 	github.com/go-rod/rod v0.101.4
 	github.com/go-rod/stealth v0.4.3
 	github.com/gobuffalo/envy v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.17
 	github.com/ysmood/gson v0.7.0 // indirect
+	github.com/zalando/go-keyring v0.2.1
 )
@@ -0,0 +1,284 @@
+// Package history keeps every availability observation and purchase price
+// scrape in a SQLite database so booking patterns and price changes can be
+// studied after the fact
+package history
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+	"github.com/lineleader/dvc-scraper/catalog"
+
+	// registers the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS availability (
+	resort      TEXT    NOT NULL,
+	room_type   TEXT    NOT NULL,
+	date        TEXT    NOT NULL,
+	rooms       INTEGER NOT NULL,
+	points      INTEGER NOT NULL,
+	observed_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS availability_night
+	ON availability (resort, room_type, date, observed_at);
+`
+
+// Observation is one night's availability as seen at ObservedAt
+type Observation struct {
+	Resort     catalog.ResortCode   `json:"resort"`
+	RoomType   catalog.RoomTypeCode `json:"roomType"`
+	Date       string               `json:"date"`
+	Rooms      int                  `json:"rooms"`
+	Points     int                  `json:"points"`
+	ObservedAt time.Time            `json:"observedAt"`
+}
+
+// Open reports whether the night had rooms
+func (o Observation) Open() bool {
+	return o.Rooms > 0
+}
+
+// OpenPeriod is a stretch of observations in which a night had rooms
+type OpenPeriod struct {
+	Resort   catalog.ResortCode   `json:"resort"`
+	RoomType catalog.RoomTypeCode `json:"roomType"`
+	Date     string               `json:"date"`
+	// Opened is the first observation with rooms
+	Opened time.Time `json:"opened"`
+	// LastSeen is the last observation with rooms
+	LastSeen time.Time `json:"lastSeen"`
+	// Closed is the first observation without rooms, zero if the night was
+	// still open at the last observation
+	Closed time.Time `json:"closed,omitempty"`
+	// MinPoints and MaxPoints are the cheapest and dearest prices seen
+	MinPoints int `json:"minPoints"`
+	MaxPoints int `json:"maxPoints"`
+}
+
+// StillOpen reports whether the night had rooms at the last observation
+func (p OpenPeriod) StillOpen() bool {
+	return p.Closed.IsZero()
+}
+
+// Duration is how long the night stayed available. It's measured to Closed,
+// or to LastSeen while the night is still open, so it's only as precise as
+// the polling interval.
+func (p OpenPeriod) Duration() time.Duration {
+	if p.StillOpen() {
+		return p.LastSeen.Sub(p.Opened)
+	}
+	return p.Closed.Sub(p.Opened)
+}
+
+// LeadTime is how far ahead of the night it opened, which shows whether it
+// was released at the 11 or 7 month window or came back from a cancellation
+func (p OpenPeriod) LeadTime() time.Duration {
+	night, err := time.ParseInLocation("2006-01-02", p.Date, p.Opened.Location())
+	if err != nil {
+		return 0
+	}
+	return night.Sub(p.Opened)
+}
+
+// Query selects the nights of one room type. From and To are inclusive
+// YYYY-MM-DD dates; empty means unbounded.
+type Query struct {
+	Resort   catalog.ResortCode
+	RoomType catalog.RoomTypeCode
+	From     string
+	To       string
+}
+
+// Store records observations in a SQLite database
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens, or creates, the database at path
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		err = fmt.Errorf("failed to open history database: %w", err)
+		return nil, err
+	}
+
+	_, err = db.Exec(schema + pricesSchema)
+	if err != nil {
+		db.Close()
+		err = fmt.Errorf("failed to create history schema: %w", err)
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Record stores every night in results as observed at observedAt
+func (s *Store) Record(ctx context.Context, results dvcscraper.AvailabilityResults, observedAt time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin transaction: %w", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO availability
+		(resort, room_type, date, rooms, points, observed_at) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		err = fmt.Errorf("failed to prepare insert: %w", err)
+		return err
+	}
+	defer stmt.Close()
+
+	at := observedAt.UnixNano()
+	for _, night := range results.Availability {
+		_, err = stmt.ExecContext(ctx, string(results.ResortCode), string(results.RoomCode), night.Day(), night.Rooms, night.Points, at)
+		if err != nil {
+			err = fmt.Errorf("failed to record %s: %w", night.Day(), err)
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit observations: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// Observations returns every observation matching q, ordered by night and
+// then time
+func (s *Store) Observations(ctx context.Context, q Query) ([]Observation, error) {
+	observations := []Observation{}
+
+	where, args := q.where()
+	rows, err := s.db.QueryContext(ctx, `SELECT resort, room_type, date, rooms, points, observed_at
+		FROM availability WHERE `+where+` ORDER BY date, observed_at`, args...)
+	if err != nil {
+		err = fmt.Errorf("failed to query observations: %w", err)
+		return observations, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var o Observation
+		var resort, roomType string
+		var at int64
+		err = rows.Scan(&resort, &roomType, &o.Date, &o.Rooms, &o.Points, &at)
+		if err != nil {
+			err = fmt.Errorf("failed to scan observation: %w", err)
+			return observations, err
+		}
+		o.Resort = catalog.ResortCode(resort)
+		o.RoomType = catalog.RoomTypeCode(roomType)
+		o.ObservedAt = time.Unix(0, at)
+		observations = append(observations, o)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to read observations: %w", err)
+		return observations, err
+	}
+
+	return observations, nil
+}
+
+// OpenPeriods returns every stretch in which a night matching q had rooms,
+// ordered by night and then time
+func (s *Store) OpenPeriods(ctx context.Context, q Query) ([]OpenPeriod, error) {
+	periods := []OpenPeriod{}
+
+	observations, err := s.Observations(ctx, q)
+	if err != nil {
+		return periods, err
+	}
+
+	var current *OpenPeriod
+	for i, o := range observations {
+		if i > 0 && o.Date != observations[i-1].Date && current != nil {
+			periods = append(periods, *current)
+			current = nil
+		}
+
+		if !o.Open() {
+			if current != nil {
+				current.Closed = o.ObservedAt
+				periods = append(periods, *current)
+				current = nil
+			}
+			continue
+		}
+
+		if current == nil {
+			current = &OpenPeriod{
+				Resort:    o.Resort,
+				RoomType:  o.RoomType,
+				Date:      o.Date,
+				Opened:    o.ObservedAt,
+				MinPoints: o.Points,
+				MaxPoints: o.Points,
+			}
+		}
+		current.LastSeen = o.ObservedAt
+		if o.Points < current.MinPoints {
+			current.MinPoints = o.Points
+		}
+		if o.Points > current.MaxPoints {
+			current.MaxPoints = o.Points
+		}
+	}
+	if current != nil {
+		periods = append(periods, *current)
+	}
+
+	return periods, nil
+}
+
+// LastOpened returns when the night last went from closed, or unseen, to
+// open. ok is false if it has never been seen open.
+func (s *Store) LastOpened(ctx context.Context, resort catalog.ResortCode, roomType catalog.RoomTypeCode, date string) (opened time.Time, ok bool, err error) {
+	period, ok, err := s.lastPeriod(ctx, resort, roomType, date)
+	return period.Opened, ok, err
+}
+
+// OpenDuration returns how long the night stayed available the last time it
+// opened. ok is false if it has never been seen open.
+func (s *Store) OpenDuration(ctx context.Context, resort catalog.ResortCode, roomType catalog.RoomTypeCode, date string) (duration time.Duration, ok bool, err error) {
+	period, ok, err := s.lastPeriod(ctx, resort, roomType, date)
+	return period.Duration(), ok, err
+}
+
+func (s *Store) lastPeriod(ctx context.Context, resort catalog.ResortCode, roomType catalog.RoomTypeCode, date string) (OpenPeriod, bool, error) {
+	periods, err := s.OpenPeriods(ctx, Query{Resort: resort, RoomType: roomType, From: date, To: date})
+	if err != nil || len(periods) == 0 {
+		return OpenPeriod{}, false, err
+	}
+	return periods[len(periods)-1], true, nil
+}
+
+func (q Query) where() (string, []interface{}) {
+	where := "resort = ? AND room_type = ?"
+	args := []interface{}{string(q.Resort), string(q.RoomType)}
+	if q.From != "" {
+		where += " AND date >= ?"
+		args = append(args, q.From)
+	}
+	if q.To != "" {
+		where += " AND date <= ?"
+		args = append(args, q.To)
+	}
+	return where, args
+}
@@ -0,0 +1,222 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+)
+
+// openTestStore opens a history database in a temporary directory
+func openTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "history.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open history: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return store, path
+}
+
+// scrape is one night's rooms and points
+type scrape struct {
+	rooms, points int
+}
+
+// record stores nights for BLT 4O as observed at
+func record(t *testing.T, store *Store, at time.Time, nights map[string]scrape) {
+	t.Helper()
+
+	results := dvcscraper.AvailabilityResults{ResortCode: "BLT", RoomCode: "4O"}
+	for date, night := range nights {
+		results.Availability = append(results.Availability, dvcscraper.DayAvailability{
+			Date:   date + "T00:00:00",
+			Rooms:  night.rooms,
+			Points: night.points,
+		})
+	}
+
+	err := store.Record(context.Background(), results, at)
+	if err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+}
+
+func TestObservations(t *testing.T) {
+	store, path := openTestStore(t)
+	ctx := context.Background()
+	t0 := time.Date(2024, time.April, 1, 9, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Hour)
+
+	record(t, store, t1, map[string]scrape{"2024-05-01": {1, 20}, "2024-05-02": {0, 22}})
+	record(t, store, t0, map[string]scrape{"2024-05-01": {0, 20}, "2024-05-03": {2, 24}})
+	err := store.Record(ctx, dvcscraper.AvailabilityResults{
+		ResortCode:   "VGF",
+		RoomCode:     "4O",
+		Availability: []dvcscraper.DayAvailability{{Date: "2024-05-01", Rooms: 1, Points: 30}},
+	}, t0)
+	if err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{
+			name:  "every night in order",
+			query: Query{Resort: "BLT", RoomType: "4O"},
+			want:  []string{"2024-05-01 0 09:00", "2024-05-01 1 10:00", "2024-05-02 0 10:00", "2024-05-03 2 09:00"},
+		},
+		{
+			name:  "from",
+			query: Query{Resort: "BLT", RoomType: "4O", From: "2024-05-02"},
+			want:  []string{"2024-05-02 0 10:00", "2024-05-03 2 09:00"},
+		},
+		{
+			name:  "to",
+			query: Query{Resort: "BLT", RoomType: "4O", To: "2024-05-01"},
+			want:  []string{"2024-05-01 0 09:00", "2024-05-01 1 10:00"},
+		},
+		{
+			name:  "other resort",
+			query: Query{Resort: "VGF", RoomType: "4O"},
+			want:  []string{"2024-05-01 1 09:00"},
+		},
+		{
+			name:  "nothing recorded",
+			query: Query{Resort: "BLT", RoomType: "1B"},
+			want:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			observations, err := store.Observations(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := []string{}
+			for _, o := range observations {
+				got = append(got, fmt.Sprintf("%s %d %s", o.Date, o.Rooms, o.ObservedAt.UTC().Format("15:04")))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// observations survive reopening the file
+	store.Close()
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to reopen history: %v", err)
+	}
+	defer reopened.Close()
+
+	observations, err := reopened.Observations(ctx, Query{Resort: "BLT", RoomType: "4O"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(observations) != 4 || observations[1].Points != 20 || !observations[1].ObservedAt.Equal(t1) {
+		t.Fatalf("reopened observations %+v", observations)
+	}
+}
+
+func TestOpenPeriods(t *testing.T) {
+	store, _ := openTestStore(t)
+	ctx := context.Background()
+	start := time.Date(2024, time.April, 1, 9, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return start.Add(time.Duration(hours) * time.Hour) }
+
+	record(t, store, at(0), map[string]scrape{"2024-05-01": {0, 20}})
+	record(t, store, at(1), map[string]scrape{"2024-05-01": {1, 20}, "2024-05-02": {1, 22}})
+	record(t, store, at(2), map[string]scrape{"2024-05-01": {2, 18}, "2024-05-02": {1, 22}})
+	record(t, store, at(3), map[string]scrape{"2024-05-01": {0, 18}})
+	record(t, store, at(4), map[string]scrape{"2024-05-01": {1, 25}})
+	record(t, store, at(6), map[string]scrape{"2024-05-01": {0, 25}})
+
+	periods, err := store.OpenPeriods(ctx, Query{Resort: "BLT", RoomType: "4O"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []OpenPeriod{
+		{Resort: "BLT", RoomType: "4O", Date: "2024-05-01", Opened: at(1), LastSeen: at(2), Closed: at(3), MinPoints: 18, MaxPoints: 20},
+		{Resort: "BLT", RoomType: "4O", Date: "2024-05-01", Opened: at(4), LastSeen: at(4), Closed: at(6), MinPoints: 25, MaxPoints: 25},
+		{Resort: "BLT", RoomType: "4O", Date: "2024-05-02", Opened: at(1), LastSeen: at(2), MinPoints: 22, MaxPoints: 22},
+	}
+	if len(periods) != len(want) {
+		t.Fatalf("got %d periods, want %d: %+v", len(periods), len(want), periods)
+	}
+	for i, got := range periods {
+		w := want[i]
+		if got.Date != w.Date || !got.Opened.Equal(w.Opened) || !got.LastSeen.Equal(w.LastSeen) || !got.Closed.Equal(w.Closed) ||
+			got.MinPoints != w.MinPoints || got.MaxPoints != w.MaxPoints || got.Resort != w.Resort || got.RoomType != w.RoomType {
+			t.Fatalf("period %d is %+v, want %+v", i, got, w)
+		}
+	}
+	if periods[0].StillOpen() || !periods[2].StillOpen() {
+		t.Fatal("wrong periods still open")
+	}
+
+	tests := []struct {
+		name         string
+		date         string
+		wantOK       bool
+		wantOpened   time.Time
+		wantDuration time.Duration
+	}{
+		{name: "reopened and closed", date: "2024-05-01", wantOK: true, wantOpened: at(4), wantDuration: 2 * time.Hour},
+		{name: "still open", date: "2024-05-02", wantOK: true, wantOpened: at(1), wantDuration: time.Hour},
+		{name: "never seen", date: "2024-05-03"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opened, ok, err := store.LastOpened(ctx, "BLT", "4O", tt.date)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tt.wantOK || !opened.Equal(tt.wantOpened) {
+				t.Fatalf("last opened %s, %v, want %s, %v", opened, ok, tt.wantOpened, tt.wantOK)
+			}
+
+			duration, ok, err := store.OpenDuration(ctx, "BLT", "4O", tt.date)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tt.wantOK || duration != tt.wantDuration {
+				t.Fatalf("open duration %s, %v, want %s, %v", duration, ok, tt.wantDuration, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLeadTime(t *testing.T) {
+	opened := time.Date(2024, time.April, 1, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		date string
+		want time.Duration
+	}{
+		{date: "2024-04-11", want: 9*24*time.Hour + 16*time.Hour},
+		{date: "2024-04-01", want: -8 * time.Hour},
+		{date: "not a date", want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := (OpenPeriod{Date: tt.date, Opened: opened}).LeadTime(); got != tt.want {
+			t.Fatalf("lead time for %s = %s, want %s", tt.date, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+)
+
+const pricesSchema = `
+CREATE TABLE IF NOT EXISTS prices (
+	key             TEXT    NOT NULL,
+	name            TEXT    NOT NULL,
+	resort          TEXT    NOT NULL,
+	price_per_point REAL    NOT NULL,
+	observed_at     INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS prices_key
+	ON prices (key, observed_at);
+`
+
+// PriceChange is a resort whose purchase price differs from the last scrape
+type PriceChange struct {
+	Price dvcscraper.ResortPrice `json:"price"`
+	// Previous is the last recorded price per point, zero for new resorts
+	Previous float64 `json:"previous"`
+	// New is set when the resort has never been recorded before
+	New        bool      `json:"new"`
+	ObservedAt time.Time `json:"observedAt"`
+}
+
+// PricePoint is a price per point as seen at ObservedAt
+type PricePoint struct {
+	PricePerPoint float64   `json:"pricePerPoint"`
+	ObservedAt    time.Time `json:"observedAt"`
+}
+
+// PriceKey identifies a resort in the price history: its catalog code, or
+// its listed name with whitespace collapsed when it isn't in the catalog
+func PriceKey(price dvcscraper.ResortPrice) string {
+	if price.Resort != "" {
+		return string(price.Resort)
+	}
+	return strings.Join(strings.Fields(price.Name), " ")
+}
+
+// RecordPrices stores a scrape of purchase prices and returns how they differ
+// from the last recorded price of each resort. The first scrape only records
+// a baseline and returns no changes.
+func (s *Store) RecordPrices(ctx context.Context, prices []dvcscraper.ResortPrice, observedAt time.Time) ([]PriceChange, error) {
+	changes := []PriceChange{}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin transaction: %w", err)
+		return changes, err
+	}
+	defer tx.Rollback()
+
+	latest, err := latestPrices(ctx, tx)
+	if err != nil {
+		return changes, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO prices
+		(key, name, resort, price_per_point, observed_at) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		err = fmt.Errorf("failed to prepare insert: %w", err)
+		return changes, err
+	}
+	defer stmt.Close()
+
+	for _, price := range prices {
+		key := PriceKey(price)
+		_, err = stmt.ExecContext(ctx, key, price.Name, string(price.Resort), price.PricePerPoint, observedAt.UnixNano())
+		if err != nil {
+			err = fmt.Errorf("failed to record price for %s: %w", key, err)
+			return changes, err
+		}
+
+		if len(latest) == 0 {
+			continue
+		}
+
+		previous, seen := latest[key]
+		if seen && previous == price.PricePerPoint {
+			continue
+		}
+		changes = append(changes, PriceChange{
+			Price:      price,
+			Previous:   previous,
+			New:        !seen,
+			ObservedAt: observedAt,
+		})
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit prices: %w", err)
+		return changes, err
+	}
+
+	return changes, nil
+}
+
+// PriceTimeline returns every recorded price for the resort with the given
+// PriceKey, oldest first
+func (s *Store) PriceTimeline(ctx context.Context, key string) ([]PricePoint, error) {
+	timelines, err := s.priceTimelines(ctx, "WHERE key = ?", key)
+	return timelines[key], err
+}
+
+// PriceTimelines returns the PriceTimeline of every recorded resort, keyed by
+// PriceKey
+func (s *Store) PriceTimelines(ctx context.Context) (map[string][]PricePoint, error) {
+	return s.priceTimelines(ctx, "")
+}
+
+// LatestPrices returns the last recorded price per point of every resort,
+// keyed by PriceKey
+func (s *Store) LatestPrices(ctx context.Context) (map[string]float64, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		err = fmt.Errorf("failed to begin transaction: %w", err)
+		return map[string]float64{}, err
+	}
+	defer tx.Rollback()
+
+	return latestPrices(ctx, tx)
+}
+
+func (s *Store) priceTimelines(ctx context.Context, where string, args ...interface{}) (map[string][]PricePoint, error) {
+	timelines := map[string][]PricePoint{}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT key, price_per_point, observed_at
+		FROM prices `+where+` ORDER BY observed_at`, args...)
+	if err != nil {
+		err = fmt.Errorf("failed to query prices: %w", err)
+		return timelines, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var point PricePoint
+		var at int64
+		err = rows.Scan(&key, &point.PricePerPoint, &at)
+		if err != nil {
+			err = fmt.Errorf("failed to scan price: %w", err)
+			return timelines, err
+		}
+		point.ObservedAt = time.Unix(0, at)
+		timelines[key] = append(timelines[key], point)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to read prices: %w", err)
+		return timelines, err
+	}
+
+	return timelines, nil
+}
+
+func latestPrices(ctx context.Context, tx *sql.Tx) (map[string]float64, error) {
+	latest := map[string]float64{}
+
+	rows, err := tx.QueryContext(ctx, `SELECT p.key, p.price_per_point FROM prices p
+		JOIN (SELECT key, MAX(observed_at) AS observed_at FROM prices GROUP BY key) l
+		ON p.key = l.key AND p.observed_at = l.observed_at`)
+	if err != nil {
+		err = fmt.Errorf("failed to query latest prices: %w", err)
+		return latest, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var price float64
+		err = rows.Scan(&key, &price)
+		if err != nil {
+			err = fmt.Errorf("failed to scan latest price: %w", err)
+			return latest, err
+		}
+		latest[key] = price
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to read latest prices: %w", err)
+		return latest, err
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,166 @@
+package history
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+)
+
+func TestPriceKey(t *testing.T) {
+	tests := []struct {
+		price dvcscraper.ResortPrice
+		want  string
+	}{
+		{price: dvcscraper.ResortPrice{Name: "Disney's Riviera Resort", Resort: "RIV"}, want: "RIV"},
+		{price: dvcscraper.ResortPrice{Name: "  The Villas at\nDisneyland Hotel "}, want: "The Villas at Disneyland Hotel"},
+	}
+
+	for _, tt := range tests {
+		if got := PriceKey(tt.price); got != tt.want {
+			t.Fatalf("PriceKey(%+v) = %q, want %q", tt.price, got, tt.want)
+		}
+	}
+}
+
+func TestRecordPrices(t *testing.T) {
+	store, _ := openTestStore(t)
+	ctx := context.Background()
+	start := time.Date(2024, time.April, 1, 9, 0, 0, 0, time.UTC)
+	at := func(days int) time.Time { return start.AddDate(0, 0, days) }
+
+	riviera := func(price float64) dvcscraper.ResortPrice {
+		return dvcscraper.ResortPrice{Name: "Disney's Riviera Resort", Resort: "RIV", PricePerPoint: price}
+	}
+	villas := func(price float64) dvcscraper.ResortPrice {
+		return dvcscraper.ResortPrice{Name: "The Villas at Disneyland Hotel", PricePerPoint: price}
+	}
+	cabins := func(price float64) dvcscraper.ResortPrice {
+		return dvcscraper.ResortPrice{Name: "The Cabins at Disney's Fort Wilderness Resort", PricePerPoint: price}
+	}
+
+	tests := []struct {
+		name   string
+		prices []dvcscraper.ResortPrice
+		want   []PriceChange
+	}{
+		{
+			name:   "first scrape is a baseline",
+			prices: []dvcscraper.ResortPrice{riviera(220), villas(275)},
+			want:   []PriceChange{},
+		},
+		{
+			name:   "unchanged",
+			prices: []dvcscraper.ResortPrice{riviera(220), villas(275)},
+			want:   []PriceChange{},
+		},
+		{
+			name:   "price drop and new resort",
+			prices: []dvcscraper.ResortPrice{riviera(210), villas(275), cabins(250)},
+			want: []PriceChange{
+				{Price: riviera(210), Previous: 220, ObservedAt: at(2)},
+				{Price: cabins(250), New: true, ObservedAt: at(2)},
+			},
+		},
+		{
+			name:   "compared with the latest price, not the highest",
+			prices: []dvcscraper.ResortPrice{riviera(220)},
+			want:   []PriceChange{{Price: riviera(220), Previous: 210, ObservedAt: at(3)}},
+		},
+		{
+			name:   "resort missing from a scrape keeps its last price",
+			prices: []dvcscraper.ResortPrice{riviera(220), villas(275), cabins(250)},
+			want:   []PriceChange{},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := store.RecordPrices(ctx, tt.prices, at(i))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(changes) != len(tt.want) {
+				t.Fatalf("got changes %+v, want %+v", changes, tt.want)
+			}
+			for j, change := range changes {
+				want := tt.want[j]
+				if change.Price != want.Price || change.Previous != want.Previous || change.New != want.New || !change.ObservedAt.Equal(want.ObservedAt) {
+					t.Fatalf("change %d is %+v, want %+v", j, change, want)
+				}
+			}
+		})
+	}
+
+	latest, err := store.LatestPrices(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantLatest := map[string]float64{"RIV": 220, "The Villas at Disneyland Hotel": 275, "The Cabins at Disney's Fort Wilderness Resort": 250}
+	if !reflect.DeepEqual(latest, wantLatest) {
+		t.Fatalf("latest prices %v, want %v", latest, wantLatest)
+	}
+}
+
+func TestPriceTimeline(t *testing.T) {
+	store, _ := openTestStore(t)
+	ctx := context.Background()
+	start := time.Date(2024, time.April, 1, 9, 0, 0, 0, time.UTC)
+
+	// recorded out of order to check the timeline is sorted by time
+	scrapes := []struct {
+		at     time.Time
+		prices []dvcscraper.ResortPrice
+	}{
+		{at: start.Add(2 * time.Hour), prices: []dvcscraper.ResortPrice{{Name: "Riviera", Resort: "RIV", PricePerPoint: 230}}},
+		{at: start, prices: []dvcscraper.ResortPrice{{Name: "Riviera", Resort: "RIV", PricePerPoint: 220}, {Name: "Villas", PricePerPoint: 275}}},
+		{at: start.Add(time.Hour), prices: []dvcscraper.ResortPrice{{Name: "Riviera", Resort: "RIV", PricePerPoint: 210}}},
+	}
+	for _, scrape := range scrapes {
+		_, err := store.RecordPrices(ctx, scrape.prices, scrape.at)
+		if err != nil {
+			t.Fatalf("failed to record prices: %v", err)
+		}
+	}
+
+	timeline, err := store.PriceTimeline(ctx, "RIV")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []PricePoint{
+		{PricePerPoint: 220, ObservedAt: start},
+		{PricePerPoint: 210, ObservedAt: start.Add(time.Hour)},
+		{PricePerPoint: 230, ObservedAt: start.Add(2 * time.Hour)},
+	}
+	if len(timeline) != len(want) {
+		t.Fatalf("got timeline %+v, want %+v", timeline, want)
+	}
+	for i, point := range timeline {
+		if point.PricePerPoint != want[i].PricePerPoint || !point.ObservedAt.Equal(want[i].ObservedAt) {
+			t.Fatalf("point %d is %+v, want %+v", i, point, want[i])
+		}
+	}
+
+	timeline, err = store.PriceTimeline(ctx, "unknown")
+	if err != nil || len(timeline) != 0 {
+		t.Fatalf("unknown resort timeline %+v, %v", timeline, err)
+	}
+
+	timelines, err := store.PriceTimelines(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(timelines) != 2 || len(timelines["RIV"]) != 3 || len(timelines["Villas"]) != 1 {
+		t.Fatalf("timelines %+v", timelines)
+	}
+
+	latest, err := store.LatestPrices(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if latest["RIV"] != 230 || latest["Villas"] != 275 {
+		t.Fatalf("latest prices %v", latest)
+	}
+}
@@ -0,0 +1,78 @@
+package dvcscraper
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lineleader/dvc-scraper/catalog"
+)
+
+// Endpoints reported to a MetricsRecorder
+const (
+	endpointLogin        = "login"
+	endpointNavigation   = "navigation"
+	endpointAvailability = "availability"
+	endpointPrices       = "purchase_prices"
+)
+
+// MetricsRecorder receives measurements from a Scraper. Endpoints are one of
+// "login", "navigation", "availability" and "purchase_prices". The metrics
+// package exports them for Prometheus.
+type MetricsRecorder interface {
+	// Login is called after every login attempt with its result
+	Login(err error)
+	// Reauth is called when a navigation found the session had expired and
+	// had to log in again
+	Reauth()
+	// Request is called after each request to an endpoint, including all of
+	// its retries
+	Request(endpoint string, took time.Duration, err error)
+	// Retry is called before each retry of a failed request
+	Retry(endpoint string)
+	// SelectorMiss is called when a request failed because an element
+	// wasn't on the page
+	SelectorMiss(selector string)
+	// ParseError is called when a request failed because the site's
+	// response couldn't be understood
+	ParseError(endpoint string)
+	// PricePerPoint is called with each resort's price from a successful
+	// price scrape. resort is the catalog code, or the listed name when the
+	// resort isn't in the catalog.
+	PricePerPoint(resort string, price float64)
+	// AvailableNights is called with the number of nights with rooms in each
+	// successful availability result
+	AvailableNights(resort catalog.ResortCode, roomType catalog.RoomTypeCode, nights int)
+}
+
+// nopMetrics discards measurements
+type nopMetrics struct{}
+
+func (nopMetrics) Login(error)                                                   {}
+func (nopMetrics) Reauth()                                                       {}
+func (nopMetrics) Request(string, time.Duration, error)                          {}
+func (nopMetrics) Retry(string)                                                  {}
+func (nopMetrics) SelectorMiss(string)                                           {}
+func (nopMetrics) ParseError(string)                                             {}
+func (nopMetrics) PricePerPoint(string, float64)                                 {}
+func (nopMetrics) AvailableNights(catalog.ResortCode, catalog.RoomTypeCode, int) {}
+
+// observe reports a finished request to endpoint that started at start
+func (s *Scraper) observe(endpoint string, start time.Time, err error) {
+	s.metrics.Request(endpoint, time.Since(start), err)
+	if err == nil {
+		return
+	}
+
+	var selectorErr *SelectorError
+	if errors.As(err, &selectorErr) {
+		s.metrics.SelectorMiss(selectorErr.Selector)
+	}
+	if errors.Is(err, ErrSiteChanged) {
+		s.metrics.ParseError(endpoint)
+	}
+}
+
+// observeNights reports the open nights in results
+func (s *Scraper) observeNights(results AvailabilityResults) {
+	s.metrics.AvailableNights(results.ResortCode, results.RoomCode, len(openNights(results.Availability)))
+}
@@ -0,0 +1,285 @@
+// Package metrics collects a Scraper's measurements and serves them in the
+// Prometheus text exposition format.
+//
+// Pass a Metrics as ScraperOptions.Metrics and serve it on /metrics:
+//
+//	m := metrics.New()
+//	scraper, err := dvcscraper.New(dvcscraper.ScraperOptions{Metrics: m})
+//	http.Handle("/metrics", m)
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+	"github.com/lineleader/dvc-scraper/catalog"
+)
+
+const namespace = "dvcscraper"
+
+// DefaultBuckets are the request latency histogram's upper bounds in seconds.
+// Logins and navigations take seconds; API requests take well under one.
+var DefaultBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// Metrics implements dvcscraper.MetricsRecorder and serves what it has
+// recorded as an http.Handler. It's safe for concurrent use.
+type Metrics struct {
+	buckets []float64
+
+	mu             sync.Mutex
+	logins         float64
+	loginFailures  float64
+	reauths        float64
+	latency        map[string]*histogram
+	requestErrors  map[string]float64
+	retries        map[string]float64
+	selectorMisses map[string]float64
+	parseErrors    map[string]float64
+	prices         map[string]float64
+	nights         map[nightsKey]float64
+}
+
+var _ dvcscraper.MetricsRecorder = (*Metrics)(nil)
+
+type nightsKey struct {
+	resort   string
+	roomType string
+}
+
+type histogram struct {
+	counts []float64
+	sum    float64
+	count  float64
+}
+
+// New returns an empty Metrics using DefaultBuckets
+func New() *Metrics {
+	return NewWithBuckets(DefaultBuckets)
+}
+
+// NewWithBuckets returns an empty Metrics with custom latency buckets
+func NewWithBuckets(buckets []float64) *Metrics {
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+
+	return &Metrics{
+		buckets:        sorted,
+		latency:        map[string]*histogram{},
+		requestErrors:  map[string]float64{},
+		retries:        map[string]float64{},
+		selectorMisses: map[string]float64{},
+		parseErrors:    map[string]float64{},
+		prices:         map[string]float64{},
+		nights:         map[nightsKey]float64{},
+	}
+}
+
+// Login counts a login attempt and whether it failed
+func (m *Metrics) Login(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.logins++
+	if err != nil {
+		m.loginFailures++
+	}
+}
+
+// Reauth counts a login forced by an expired session during navigation
+func (m *Metrics) Reauth() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reauths++
+}
+
+// Request records a request's latency and whether it failed
+func (m *Metrics) Request(endpoint string, took time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.latency[endpoint]
+	if !ok {
+		h = &histogram{counts: make([]float64, len(m.buckets))}
+		m.latency[endpoint] = h
+	}
+
+	seconds := took.Seconds()
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+
+	if err != nil {
+		m.requestErrors[endpoint]++
+	}
+}
+
+// Retry counts a retry
+func (m *Metrics) Retry(endpoint string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.retries[endpoint]++
+}
+
+// SelectorMiss counts a missing element
+func (m *Metrics) SelectorMiss(selector string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.selectorMisses[selector]++
+}
+
+// ParseError counts a response that couldn't be understood
+func (m *Metrics) ParseError(endpoint string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.parseErrors[endpoint]++
+}
+
+// PricePerPoint sets a resort's latest price
+func (m *Metrics) PricePerPoint(resort string, price float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prices[resort] = price
+}
+
+// AvailableNights sets the number of open nights in the latest result for a
+// room type
+func (m *Metrics) AvailableNights(resort catalog.ResortCode, roomType catalog.RoomTypeCode, nights int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nights[nightsKey{string(resort), string(roomType)}] = float64(nights)
+}
+
+// ServeHTTP writes every metric in the Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes every metric in the Prometheus text format to w
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := strings.Builder{}
+
+	header(&b, "logins_total", "counter", "Login attempts.")
+	sample(&b, "logins_total", nil, m.logins)
+	header(&b, "login_failures_total", "counter", "Login attempts that failed.")
+	sample(&b, "login_failures_total", nil, m.loginFailures)
+	header(&b, "reauths_total", "counter", "Logins forced by an expired session during navigation.")
+	sample(&b, "reauths_total", nil, m.reauths)
+
+	header(&b, "request_duration_seconds", "histogram", "Time taken by requests, including retries, by endpoint.")
+	for _, endpoint := range sortedKeys(m.latency) {
+		h := m.latency[endpoint]
+		for i, bound := range m.buckets {
+			sample(&b, "request_duration_seconds_bucket", []string{"endpoint", endpoint, "le", formatFloat(bound)}, h.counts[i])
+		}
+		sample(&b, "request_duration_seconds_bucket", []string{"endpoint", endpoint, "le", "+Inf"}, h.count)
+		sample(&b, "request_duration_seconds_sum", []string{"endpoint", endpoint}, h.sum)
+		sample(&b, "request_duration_seconds_count", []string{"endpoint", endpoint}, h.count)
+	}
+
+	counterVec(&b, "request_errors_total", "Requests that failed after any retries, by endpoint.", "endpoint", m.requestErrors)
+	counterVec(&b, "retries_total", "Retries of failed requests, by endpoint.", "endpoint", m.retries)
+	counterVec(&b, "selector_misses_total", "Requests that failed because an element was missing, by selector.", "selector", m.selectorMisses)
+	counterVec(&b, "parse_errors_total", "Responses that couldn't be understood, by endpoint.", "endpoint", m.parseErrors)
+
+	header(&b, "price_per_point_dollars", "gauge", "Latest purchase price per point, by resort.")
+	for _, resort := range sortedKeys(m.prices) {
+		sample(&b, "price_per_point_dollars", []string{"resort", resort}, m.prices[resort])
+	}
+
+	header(&b, "available_nights", "gauge", "Nights with rooms in the latest availability result, by resort and room type.")
+	keys := make([]nightsKey, 0, len(m.nights))
+	for key := range m.nights {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].resort != keys[j].resort {
+			return keys[i].resort < keys[j].resort
+		}
+		return keys[i].roomType < keys[j].roomType
+	})
+	for _, key := range keys {
+		sample(&b, "available_nights", []string{"resort", key.resort, "room_type", key.roomType}, m.nights[key])
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func counterVec(b *strings.Builder, name, help, label string, values map[string]float64) {
+	header(b, name, "counter", help)
+	for _, key := range sortedKeys(values) {
+		sample(b, name, []string{label, key}, values[key])
+	}
+}
+
+func header(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s_%s %s\n", namespace, name, help)
+	fmt.Fprintf(b, "# TYPE %s_%s %s\n", namespace, name, kind)
+}
+
+// sample writes one line; labels are name, value pairs
+func sample(b *strings.Builder, name string, labels []string, value float64) {
+	b.WriteString(namespace + "_" + name)
+	if len(labels) > 0 {
+		pairs := []string{}
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], escapeLabel(labels[i+1])))
+		}
+		b.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	b.WriteString(" " + formatFloat(value) + "\n")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	switch values := m.(type) {
+	case map[string]float64:
+		for key := range values {
+			keys = append(keys, key)
+		}
+	case map[string]*histogram:
+		for key := range values {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteTo(t *testing.T) {
+	m := NewWithBuckets([]float64{1, 0.5})
+	m.Login(nil)
+	m.Login(errors.New("bad password"))
+	m.Reauth()
+	m.Request("availability", 300*time.Millisecond, nil)
+	m.Request("availability", 2*time.Second, errors.New("timeout"))
+	m.Retry("availability")
+	m.SelectorMiss(`a[href="x"]`)
+	m.ParseError("purchase_prices")
+	m.PricePerPoint("BLT", 215.5)
+	m.AvailableNights("BLT", "4O", 3)
+	m.AvailableNights("AKV", "2B", 0)
+
+	b := strings.Builder{}
+	_, err := m.WriteTo(&b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := b.String()
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "logins", want: "dvcscraper_logins_total 2\n"},
+		{name: "login failures", want: "dvcscraper_login_failures_total 1\n"},
+		{name: "reauths", want: "dvcscraper_reauths_total 1\n"},
+		{name: "counter type", want: "# TYPE dvcscraper_logins_total counter\n"},
+		{name: "histogram type", want: "# TYPE dvcscraper_request_duration_seconds histogram\n"},
+		{name: "sorted buckets", want: "dvcscraper_request_duration_seconds_bucket{endpoint=\"availability\",le=\"0.5\"} 1\n" +
+			"dvcscraper_request_duration_seconds_bucket{endpoint=\"availability\",le=\"1\"} 1\n" +
+			"dvcscraper_request_duration_seconds_bucket{endpoint=\"availability\",le=\"+Inf\"} 2\n"},
+		{name: "histogram sum", want: "dvcscraper_request_duration_seconds_sum{endpoint=\"availability\"} 2.3\n"},
+		{name: "histogram count", want: "dvcscraper_request_duration_seconds_count{endpoint=\"availability\"} 2\n"},
+		{name: "request errors", want: "dvcscraper_request_errors_total{endpoint=\"availability\"} 1\n"},
+		{name: "retries", want: "dvcscraper_retries_total{endpoint=\"availability\"} 1\n"},
+		{name: "escaped label", want: "dvcscraper_selector_misses_total{selector=\"a[href=\\\"x\\\"]\"} 1\n"},
+		{name: "parse errors", want: "dvcscraper_parse_errors_total{endpoint=\"purchase_prices\"} 1\n"},
+		{name: "gauge type", want: "# TYPE dvcscraper_price_per_point_dollars gauge\n"},
+		{name: "price", want: "dvcscraper_price_per_point_dollars{resort=\"BLT\"} 215.5\n"},
+		{name: "sorted nights", want: "dvcscraper_available_nights{resort=\"AKV\",room_type=\"2B\"} 0\n" +
+			"dvcscraper_available_nights{resort=\"BLT\",room_type=\"4O\"} 3\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(got, tt.want) {
+				t.Fatalf("missing %q in:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "plain", want: "plain"},
+		{value: `back\slash`, want: `back\\slash`},
+		{value: `"quoted"`, want: `\"quoted\"`},
+		{value: "two\nlines", want: `two\nlines`},
+	}
+
+	for _, tt := range tests {
+		got := escapeLabel(tt.value)
+		if got != tt.want {
+			t.Fatalf("escapeLabel(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	m := New()
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "dvcscraper_logins_total 0\n") {
+		t.Fatalf("empty metrics missing zero counters:\n%s", rec.Body.String())
+	}
+}
@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"text/template"
+)
+
+// Command runs a program for each Message. The Message is written to its
+// stdin as JSON and DVC_KIND, DVC_TITLE, DVC_BODY and DVC_KEY are set in its
+// environment.
+type Command struct {
+	// Command is the program and its arguments
+	Command []string
+	// Template renders DVC_BODY
+	Template *template.Template
+}
+
+// Notify runs the command and fails if it exits non-zero
+func (c Command) Notify(ctx context.Context, msg Message) error {
+	if len(c.Command) == 0 {
+		return errors.New("command notifier has no command")
+	}
+
+	body, err := render(c.Template, msg)
+	if err != nil {
+		return err
+	}
+	msg.Body = body
+
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		err = fmt.Errorf("failed to marshal message: %w", err)
+		return err
+	}
+
+	stderr := bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(raw)
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(),
+		"DVC_KIND="+msg.Kind,
+		"DVC_TITLE="+msg.Title,
+		"DVC_BODY="+msg.Body,
+		"DVC_KEY="+msg.Key,
+	)
+
+	err = cmd.Run()
+	if err != nil {
+		err = fmt.Errorf("failed to run notify command %s: %w: %s", c.Command[0], err, bytes.TrimSpace(stderr.Bytes()))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	out := filepath.Join(t.TempDir(), "out")
+	script := `printf '%s\n%s\n%s\n%s\n' "$DVC_KIND" "$DVC_TITLE" "$DVC_BODY" "$DVC_KEY" > "$1"; cat >> "$1"`
+	command := Command{
+		Command:  []string{"sh", "-c", script, "sh", out},
+		Template: template.Must(template.New("body").Parse("{{.Body}} ({{.Key}})")),
+	}
+
+	msg := testMessage()
+	err := command.Notify(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	raw, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read command output: %v", err)
+	}
+	lines := strings.SplitN(string(raw), "\n", 5)
+	body := msg.Body + " (" + msg.Key + ")"
+	want := []string{msg.Kind, msg.Title, body, msg.Key}
+	for i, w := range want {
+		if lines[i] != w {
+			t.Fatalf("line %d is %q, want %q", i+1, lines[i], w)
+		}
+	}
+
+	stdin := Message{}
+	err = json.Unmarshal([]byte(lines[4]), &stdin)
+	if err != nil {
+		t.Fatalf("failed to decode stdin %q: %v", lines[4], err)
+	}
+	if stdin.Title != msg.Title || stdin.Body != body {
+		t.Fatalf("stdin message %+v", stdin)
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	tests := []struct {
+		name    string
+		command Command
+		wantErr string
+	}{
+		{name: "no command", command: Command{}, wantErr: "no command"},
+		{name: "exit status", command: Command{Command: []string{"sh", "-c", "echo mailbox full >&2; exit 3"}}, wantErr: "exit status 3: mailbox full"},
+		{name: "missing program", command: Command{Command: []string{"dvc-scraper-no-such-program"}}, wantErr: "failed to run notify command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.command.Notify(context.Background(), testMessage())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Email sends each Message through an SMTP server
+type Email struct {
+	// Addr is the server as host:port
+	Addr string
+	// Username and Password enable PLAIN auth when Username is set
+	Username string
+	Password string
+	From     string
+	To       []string
+	// Template renders the message body
+	Template *template.Template
+}
+
+// Notify sends msg
+func (e Email) Notify(ctx context.Context, msg Message) error {
+	if len(e.To) == 0 {
+		return errors.New("email notifier has no recipients")
+	}
+
+	body, err := render(e.Template, msg)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, err := net.SplitHostPort(e.Addr)
+		if err != nil {
+			err = fmt.Errorf("failed to parse smtp address: %w", err)
+			return err
+		}
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+
+	raw := e.message(msg, body)
+
+	// net/smtp has no context support so the send runs until it finishes or
+	// fails, and ctx only stops the wait for it
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(e.Addr, auth, e.From, e.To, raw)
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to send email: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+func (e Email) message(msg Message, body string) []byte {
+	at := msg.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", headerValue(msg.Title)))
+	fmt.Fprintf(&b, "Date: %s\r\n", at.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+
+	return []byte(b.String())
+}
+
+// headerValue keeps a value on one header line
+func headerValue(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
@@ -0,0 +1,191 @@
+package notify
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// delivery is a message received by the test SMTP server
+type delivery struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// startTestSMTPServer accepts mail for any recipient except those starting
+// with "reject", offering PLAIN auth and no TLS
+func startTestSMTPServer(t *testing.T) (string, <-chan delivery) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	deliveries := make(chan delivery, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, deliveries)
+		}
+	}()
+
+	return listener.Addr().String(), deliveries
+}
+
+func serveSMTP(conn net.Conn, deliveries chan<- delivery) {
+	tp := textproto.NewConn(conn)
+	defer tp.Close()
+
+	d := delivery{}
+	_ = tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			verb, arg = line[:i], line[i+1:]
+		}
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			_ = tp.PrintfLine("250-localhost")
+			_ = tp.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			raw, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			d.auth = strings.ReplaceAll(string(raw), "\x00", " ")
+			_ = tp.PrintfLine("235 2.7.0 Authentication successful")
+		case "MAIL":
+			d.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			_ = tp.PrintfLine("250 OK")
+		case "RCPT":
+			to := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			if strings.HasPrefix(to, "reject") {
+				_ = tp.PrintfLine("550 no such user")
+				continue
+			}
+			d.to = append(d.to, to)
+			_ = tp.PrintfLine("250 OK")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			d.data = string(data)
+			deliveries <- d
+			d = delivery{}
+			_ = tp.PrintfLine("250 OK")
+		case "RSET":
+			d = delivery{}
+			_ = tp.PrintfLine("250 OK")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestEmail(t *testing.T) {
+	addr, deliveries := startTestSMTPServer(t)
+
+	email := Email{
+		Addr:     addr,
+		Username: "alerts",
+		Password: "secret",
+		From:     "dvc@example.com",
+		To:       []string{"alice@example.com", "bob@example.com"},
+	}
+	msg := testMessage()
+	msg.Body = "line one\nline two"
+	err := email.Notify(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := <-deliveries
+	if got.auth != " alerts secret" {
+		t.Fatalf("auth %q", got.auth)
+	}
+	if got.from != "dvc@example.com" || strings.Join(got.to, ",") != "alice@example.com,bob@example.com" {
+		t.Fatalf("envelope from %s to %v", got.from, got.to)
+	}
+	for _, want := range []string{
+		"From: dvc@example.com\n",
+		"To: alice@example.com, bob@example.com\n",
+		"Subject: 2024-04-02 opened at Bay Lake Tower\n",
+		"Date: Mon, 01 Apr 2024 09:00:00 +0000\n",
+		"Content-Type: text/plain; charset=utf-8\n",
+		"\n\nline one\nline two\n",
+	} {
+		if !strings.Contains(got.data, want) {
+			t.Fatalf("missing %q in:\n%s", want, got.data)
+		}
+	}
+}
+
+func TestEmailWithoutAuth(t *testing.T) {
+	addr, deliveries := startTestSMTPServer(t)
+
+	err := Email{Addr: addr, From: "dvc@example.com", To: []string{"alice@example.com"}}.Notify(context.Background(), testMessage())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := <-deliveries; got.auth != "" {
+		t.Fatalf("authenticated without a username: %q", got.auth)
+	}
+}
+
+func TestEmailErrors(t *testing.T) {
+	addr, _ := startTestSMTPServer(t)
+
+	tests := []struct {
+		name    string
+		email   Email
+		wantErr string
+	}{
+		{name: "no recipients", email: Email{Addr: addr}, wantErr: "no recipients"},
+		{name: "bad address", email: Email{Addr: "localhost", Username: "a", To: []string{"alice@example.com"}}, wantErr: "failed to parse smtp address"},
+		{name: "rejected recipient", email: Email{Addr: addr, From: "dvc@example.com", To: []string{"reject@example.com"}}, wantErr: "no such user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.email.Notify(context.Background(), testMessage())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestEmailCancelled(t *testing.T) {
+	// a server that never greets leaves the send waiting
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err = Email{Addr: listener.Addr().String(), To: []string{"alice@example.com"}}.Notify(ctx, testMessage())
+	if err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,367 @@
+// Package notify sends availability and price changes to people: webhooks,
+// Slack, email, push services and local commands
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+	"github.com/lineleader/dvc-scraper/catalog"
+)
+
+// DefaultDedupeWindow is how long Dedupe remembers a Message when its Window
+// isn't set
+const DefaultDedupeWindow = 24 * time.Hour
+
+// Kinds of Message
+const (
+	KindAvailability = "availability"
+	KindPrice        = "price"
+)
+
+// Message is a notification about one change
+type Message struct {
+	Kind  string `json:"kind"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// Key identifies the change for de-duplication. Messages with the same
+	// Key describe the same change.
+	Key  string      `json:"key"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Notifier delivers Messages
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// NotifierFunc adapts a function to a Notifier
+type NotifierFunc func(ctx context.Context, msg Message) error
+
+// Notify calls f
+func (f NotifierFunc) Notify(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+// Multi sends every Message to each of its Notifiers. A failing Notifier
+// doesn't stop the others.
+type Multi []Notifier
+
+// Notify sends msg to every Notifier and returns their combined errors
+func (m Multi) Notify(ctx context.Context, msg Message) error {
+	msgs := []string{}
+	for _, n := range m {
+		err := n.Notify(ctx, msg)
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+
+	if len(msgs) > 0 {
+		return fmt.Errorf("failed to notify %d of %d sinks: %s", len(msgs), len(m), strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// AvailabilityMessage describes an availability change
+func AvailabilityMessage(event dvcscraper.AvailabilityEvent) Message {
+	resort := event.Resort.Name()
+	room := string(event.RoomType)
+	if roomType, ok := catalog.RoomTypeByCode(event.Resort, event.RoomType); ok {
+		room = fmt.Sprintf("%s (%s view)", roomType.Name, roomType.View)
+	}
+
+	msg := Message{
+		Kind: KindAvailability,
+		Key:  fmt.Sprintf("%s/%s/%s/%s/%d/%d", event.Kind, event.Resort, event.RoomType, event.Date, event.Current.Rooms, event.Current.Points),
+		Time: event.ObservedAt,
+		Data: event,
+	}
+
+	switch event.Kind {
+	case dvcscraper.NightOpened:
+		msg.Title = fmt.Sprintf("%s opened at %s", event.Date, resort)
+		msg.Body = fmt.Sprintf("%s on %s is available: %d rooms for %d points.", room, event.Date, event.Current.Rooms, event.Current.Points)
+	case dvcscraper.NightClosed:
+		msg.Title = fmt.Sprintf("%s closed at %s", event.Date, resort)
+		msg.Body = fmt.Sprintf("%s on %s is no longer available.", room, event.Date)
+	case dvcscraper.RoomsChanged:
+		msg.Title = fmt.Sprintf("%s rooms changed at %s", event.Date, resort)
+		msg.Body = fmt.Sprintf("%s on %s: %d => %d rooms.", room, event.Date, event.Previous.Rooms, event.Current.Rooms)
+	case dvcscraper.PointsChanged:
+		msg.Title = fmt.Sprintf("%s points changed at %s", event.Date, resort)
+		msg.Body = fmt.Sprintf("%s on %s: %d => %d points.", room, event.Date, event.Previous.Points, event.Current.Points)
+	default:
+		msg.Title = fmt.Sprintf("%s changed at %s", event.Date, resort)
+		msg.Body = fmt.Sprintf("%s on %s changed.", room, event.Date)
+	}
+
+	return msg
+}
+
+// PriceMessage describes a change in the purchase price of a resort. A
+// previous price of zero means the resort is newly listed.
+func PriceMessage(price dvcscraper.ResortPrice, previous float64) Message {
+	msg := Message{
+		Kind: KindPrice,
+		Key:  fmt.Sprintf("price/%s/%.2f", price.Name, price.PricePerPoint),
+		Time: time.Now(),
+		Data: price,
+	}
+
+	name := strings.Join(strings.Fields(price.Name), " ")
+	if previous == 0 {
+		msg.Title = fmt.Sprintf("New resort listed: %s", name)
+		msg.Body = fmt.Sprintf("%s is listed at $%.2f per point.", name, price.PricePerPoint)
+	} else {
+		msg.Title = fmt.Sprintf("Price change: %s", name)
+		msg.Body = fmt.Sprintf("%s\n$%.2f => $%.2f per point.", name, previous, price.PricePerPoint)
+	}
+
+	return msg
+}
+
+// render executes tmpl with msg, or returns msg.Body when tmpl is nil
+func render(tmpl *template.Template, msg Message) (string, error) {
+	if tmpl == nil {
+		return msg.Body, nil
+	}
+
+	buf := bytes.Buffer{}
+	err := tmpl.Execute(&buf, msg)
+	if err != nil {
+		err = fmt.Errorf("failed to render template: %w", err)
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// Dedupe drops Messages whose Key was already sent within Window
+type Dedupe struct {
+	Notifier Notifier
+	// Window defaults to DefaultDedupeWindow
+	Window time.Duration
+
+	mu   sync.Mutex
+	sent map[string]time.Time
+}
+
+// NewDedupe wraps n so repeats within window are dropped. A window of zero
+// means DefaultDedupeWindow.
+func NewDedupe(n Notifier, window time.Duration) *Dedupe {
+	return &Dedupe{Notifier: n, Window: window}
+}
+
+// Notify forwards msg unless it's a repeat
+func (d *Dedupe) Notify(ctx context.Context, msg Message) error {
+	key := msg.Key
+	if key == "" {
+		key = msg.Title + "\n" + msg.Body
+	}
+
+	window := d.Window
+	if window <= 0 {
+		window = DefaultDedupeWindow
+	}
+
+	now := time.Now()
+	d.mu.Lock()
+	if d.sent == nil {
+		d.sent = map[string]time.Time{}
+	}
+	for k, at := range d.sent {
+		if now.Sub(at) > window {
+			delete(d.sent, k)
+		}
+	}
+	if _, ok := d.sent[key]; ok {
+		d.mu.Unlock()
+		return nil
+	}
+	d.sent[key] = now
+	d.mu.Unlock()
+
+	err := d.Notifier.Notify(ctx, msg)
+	if err != nil {
+		d.mu.Lock()
+		delete(d.sent, key)
+		d.mu.Unlock()
+	}
+	return err
+}
+
+// QuietHours holds back Messages between Start and End each day, e.g. from
+// 22:00 to 07:00. Held Messages are sent when quiet hours end, or dropped when
+// Drop is set. A failure sending them is returned by the next call to Notify
+// or Flush.
+type QuietHours struct {
+	Notifier Notifier
+	// Start and End are offsets from midnight
+	Start time.Duration
+	End   time.Duration
+	// Location defaults to local time
+	Location *time.Location
+	Drop     bool
+
+	mu       sync.Mutex
+	held     []Message
+	timer    *time.Timer
+	flushErr error
+}
+
+// NewQuietHours wraps n so nothing is sent from start to end, given as "15:04"
+func NewQuietHours(n Notifier, start, end string) (*QuietHours, error) {
+	startOffset, err := parseClock(start)
+	if err != nil {
+		return nil, err
+	}
+	endOffset, err := parseClock(end)
+	if err != nil {
+		return nil, err
+	}
+
+	return &QuietHours{Notifier: n, Start: startOffset, End: endOffset}, nil
+}
+
+// Notify sends msg, and any held Messages, unless it's quiet hours
+func (q *QuietHours) Notify(ctx context.Context, msg Message) error {
+	q.mu.Lock()
+	now := time.Now()
+	if q.quiet(now) {
+		if !q.Drop {
+			q.held = append(q.held, msg)
+			q.schedule(now)
+		}
+		q.mu.Unlock()
+		return nil
+	}
+	pending := append(q.held, msg)
+	q.held = nil
+	flushErr := q.takeFlushErr()
+	q.mu.Unlock()
+
+	err := notifyAll(ctx, q.Notifier, pending)
+	if err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// Flush sends held Messages if quiet hours are over. Held Messages are flushed
+// when quiet hours end without calling Flush.
+func (q *QuietHours) Flush(ctx context.Context) error {
+	q.mu.Lock()
+	if q.quiet(time.Now()) {
+		q.mu.Unlock()
+		return nil
+	}
+	pending := q.held
+	q.held = nil
+	flushErr := q.takeFlushErr()
+	q.mu.Unlock()
+
+	err := notifyAll(ctx, q.Notifier, pending)
+	if err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// schedule starts the timer that flushes held Messages when quiet hours end.
+// q.mu must be held.
+func (q *QuietHours) schedule(now time.Time) {
+	if q.timer != nil {
+		return
+	}
+	q.timer = time.AfterFunc(q.untilEnd(now), q.flushHeld)
+}
+
+// flushHeld sends held Messages once quiet hours have ended
+func (q *QuietHours) flushHeld() {
+	q.mu.Lock()
+	q.timer = nil
+	now := time.Now()
+	if q.quiet(now) {
+		// woken early, e.g. by a clock change
+		q.schedule(now)
+		q.mu.Unlock()
+		return
+	}
+	pending := q.held
+	q.held = nil
+	q.mu.Unlock()
+
+	err := notifyAll(context.Background(), q.Notifier, pending)
+	if err != nil {
+		q.mu.Lock()
+		q.flushErr = err
+		q.mu.Unlock()
+	}
+}
+
+// takeFlushErr returns and clears the error from the last timed flush. q.mu
+// must be held.
+func (q *QuietHours) takeFlushErr() error {
+	err := q.flushErr
+	q.flushErr = nil
+	return err
+}
+
+func (q *QuietHours) location() *time.Location {
+	if q.Location == nil {
+		return time.Local
+	}
+	return q.Location
+}
+
+func (q *QuietHours) quiet(now time.Time) bool {
+	now = now.In(q.location())
+
+	y, m, d := now.Date()
+	offset := now.Sub(time.Date(y, m, d, 0, 0, 0, 0, now.Location()))
+
+	if q.Start <= q.End {
+		return offset >= q.Start && offset < q.End
+	}
+	return offset >= q.Start || offset < q.End
+}
+
+// untilEnd returns how long after now quiet hours next end
+func (q *QuietHours) untilEnd(now time.Time) time.Duration {
+	now = now.In(q.location())
+
+	y, m, d := now.Date()
+	end := time.Date(y, m, d, 0, 0, 0, 0, now.Location()).Add(q.End)
+	if !end.After(now) {
+		end = time.Date(y, m, d+1, 0, 0, 0, 0, now.Location()).Add(q.End)
+	}
+	return end.Sub(now)
+}
+
+func notifyAll(ctx context.Context, n Notifier, msgs []Message) error {
+	var firstErr error
+	for _, msg := range msgs {
+		err := n.Notify(ctx, msg)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func parseClock(clock string) (time.Duration, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		err = fmt.Errorf("failed to parse time of day '%s': %w", clock, err)
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -0,0 +1,215 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEmailSubjectEncoding(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		want  string
+	}{
+		{name: "ascii", title: "BLT studio opened", want: "Subject: BLT studio opened\r\n"},
+		{name: "whitespace collapsed", title: "BLT\nstudio  opened", want: "Subject: BLT studio opened\r\n"},
+		{name: "non-ascii", title: "Animal Kingdom Villas – Jambo House", want: "Subject: =?utf-8?q?Animal_Kingdom_Villas_=E2=80=93_Jambo_House?=\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := string(Email{From: "a@example.com", To: []string{"b@example.com"}}.message(Message{Title: tt.title}, "body"))
+			if !strings.Contains(raw, tt.want) {
+				t.Fatalf("missing %q in:\n%s", tt.want, raw)
+			}
+		})
+	}
+}
+
+func TestDedupeDefaultWindow(t *testing.T) {
+	sent := 0
+	counter := NotifierFunc(func(context.Context, Message) error {
+		sent++
+		return nil
+	})
+
+	dedupe := &Dedupe{Notifier: counter}
+	for i := 0; i < 3; i++ {
+		err := dedupe.Notify(context.Background(), Message{Title: "same", Key: "k"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if sent != 1 {
+		t.Fatalf("sent %d messages, want 1", sent)
+	}
+}
+
+func TestQuietHoursQuiet(t *testing.T) {
+	day := func(hour, minute int) time.Time {
+		return time.Date(2024, time.April, 2, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name       string
+		start, end string
+		now        time.Time
+		want       bool
+		wantUntil  time.Duration
+	}{
+		{name: "overnight late", start: "22:00", end: "07:00", now: day(23, 30), want: true, wantUntil: 7*time.Hour + 30*time.Minute},
+		{name: "overnight early", start: "22:00", end: "07:00", now: day(6, 59), want: true, wantUntil: time.Minute},
+		{name: "overnight at end", start: "22:00", end: "07:00", now: day(7, 0), want: false, wantUntil: 24 * time.Hour},
+		{name: "overnight daytime", start: "22:00", end: "07:00", now: day(12, 0), want: false, wantUntil: 19 * time.Hour},
+		{name: "same day", start: "09:00", end: "17:00", now: day(9, 0), want: true, wantUntil: 8 * time.Hour},
+		{name: "same day after", start: "09:00", end: "17:00", now: day(17, 30), want: false, wantUntil: 23*time.Hour + 30*time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := NewQuietHours(nil, tt.start, tt.end)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			q.Location = time.UTC
+
+			if got := q.quiet(tt.now); got != tt.want {
+				t.Fatalf("quiet at %s = %v, want %v", tt.now.Format("15:04"), got, tt.want)
+			}
+			if got := q.untilEnd(tt.now); got != tt.wantUntil {
+				t.Fatalf("until end %s, want %s", got, tt.wantUntil)
+			}
+		})
+	}
+}
+
+func TestNewQuietHoursInvalid(t *testing.T) {
+	_, err := NewQuietHours(nil, "10pm", "07:00")
+	if err == nil {
+		t.Fatal("expected error for an invalid time of day")
+	}
+}
+
+// recorder keeps the Messages sent to it and fails those titled "fail"
+type recorder struct {
+	mu   sync.Mutex
+	sent []string
+	got  chan string
+}
+
+func newRecorder() *recorder {
+	return &recorder{got: make(chan string, 10)}
+}
+
+func (r *recorder) Notify(ctx context.Context, msg Message) error {
+	r.mu.Lock()
+	r.sent = append(r.sent, msg.Title)
+	r.mu.Unlock()
+	r.got <- msg.Title
+	if msg.Title == "fail" {
+		return errors.New("sink down")
+	}
+	return nil
+}
+
+func (r *recorder) titles() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.sent...)
+}
+
+// quietFor returns quiet hours in UTC that started an hour ago and end after d
+func quietFor(n Notifier, d time.Duration) *QuietHours {
+	now := time.Now().UTC()
+	y, m, day := now.Date()
+	offset := now.Sub(time.Date(y, m, day, 0, 0, 0, 0, time.UTC))
+	wrap := func(d time.Duration) time.Duration {
+		return ((d % (24 * time.Hour)) + 24*time.Hour) % (24 * time.Hour)
+	}
+
+	return &QuietHours{Notifier: n, Start: wrap(offset - time.Hour), End: wrap(offset + d), Location: time.UTC}
+}
+
+func TestQuietHoursHoldsAndDrops(t *testing.T) {
+	sink := newRecorder()
+	q := quietFor(sink, time.Hour)
+
+	err := q.Notify(context.Background(), Message{Title: "held"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = q.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sink.titles()) != 0 {
+		t.Fatalf("sent %v during quiet hours", sink.titles())
+	}
+	q.mu.Lock()
+	held := len(q.held)
+	q.timer.Stop()
+	q.mu.Unlock()
+	if held != 1 {
+		t.Fatalf("%d messages held, want 1", held)
+	}
+
+	dropping := quietFor(sink, time.Hour)
+	dropping.Drop = true
+	_ = dropping.Notify(context.Background(), Message{Title: "dropped"})
+	if len(dropping.held) != 0 || dropping.timer != nil {
+		t.Fatal("dropping quiet hours held a message")
+	}
+}
+
+func TestQuietHoursFlushesAtEnd(t *testing.T) {
+	sink := newRecorder()
+	q := quietFor(sink, 100*time.Millisecond)
+
+	for _, title := range []string{"fail", "second"} {
+		err := q.Notify(context.Background(), Message{Title: title})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	for _, want := range []string{"fail", "second"} {
+		select {
+		case got := <-sink.got:
+			if got != want {
+				t.Fatalf("sent %s, want %s", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s not sent when quiet hours ended", want)
+		}
+	}
+
+	// the failure in the timed flush is reported by the next Notify
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		q.mu.Lock()
+		stored := q.flushErr != nil
+		q.mu.Unlock()
+		if stored {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed flush didn't keep its error")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	err := q.Notify(context.Background(), Message{Title: "after"})
+	if err == nil || !strings.Contains(err.Error(), "sink down") {
+		t.Fatalf("expected the flush error, got %v", err)
+	}
+	err = q.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("flush error reported twice: %v", err)
+	}
+	if got := sink.titles(); strings.Join(got, ",") != "fail,second,after" {
+		t.Fatalf("sent %v", got)
+	}
+}
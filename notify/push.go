@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const defaultNtfyServer = "https://ntfy.sh"
+
+// Ntfy publishes each Message to an ntfy topic
+type Ntfy struct {
+	// Server defaults to https://ntfy.sh
+	Server string
+	Topic  string
+	// Token is sent as a bearer token for protected topics
+	Token string
+	// Priority is 1 (min) to 5 (max); zero leaves the server default
+	Priority int
+	Client   *http.Client
+	// Template renders the message body
+	Template *template.Template
+}
+
+// Notify sends msg
+func (n Ntfy) Notify(ctx context.Context, msg Message) error {
+	if n.Topic == "" {
+		return errors.New("ntfy notifier has no topic")
+	}
+
+	body, err := render(n.Template, msg)
+	if err != nil {
+		return err
+	}
+
+	server := n.Server
+	if server == "" {
+		server = defaultNtfyServer
+	}
+
+	headers := map[string]string{
+		"Title": mime.QEncoding.Encode("utf-8", headerValue(msg.Title)),
+		"Tags":  msg.Kind,
+	}
+	if n.Token != "" {
+		headers["Authorization"] = "Bearer " + n.Token
+	}
+	if n.Priority > 0 {
+		headers["Priority"] = strconv.Itoa(n.Priority)
+	}
+
+	return post(ctx, n.Client, strings.TrimRight(server, "/")+"/"+n.Topic, headers, []byte(body))
+}
+
+// Gotify sends each Message to a Gotify server
+type Gotify struct {
+	Server string
+	// Token is the application token
+	Token    string
+	Priority int
+	Client   *http.Client
+	// Template renders the message body
+	Template *template.Template
+}
+
+// Notify sends msg
+func (g Gotify) Notify(ctx context.Context, msg Message) error {
+	if g.Server == "" {
+		return errors.New("gotify notifier has no server")
+	}
+
+	body, err := render(g.Template, msg)
+	if err != nil {
+		return err
+	}
+
+	payload := struct {
+		Title    string `json:"title"`
+		Message  string `json:"message"`
+		Priority int    `json:"priority,omitempty"`
+	}{msg.Title, body, g.Priority}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		err = fmt.Errorf("failed to marshal gotify payload: %w", err)
+		return err
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+		"X-Gotify-Key": g.Token,
+	}
+
+	return post(ctx, g.Client, strings.TrimRight(g.Server, "/")+"/message", headers, raw)
+}
@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestNtfy(t *testing.T) {
+	server, requests := startTestServer(t, http.StatusOK, "{}")
+
+	tests := []struct {
+		name         string
+		ntfy         Ntfy
+		title        string
+		wantPath     string
+		wantTitle    string
+		wantAuth     string
+		wantPriority string
+	}{
+		{
+			name:      "ascii title",
+			ntfy:      Ntfy{Server: server.URL, Topic: "dvc"},
+			title:     "2024-04-02 opened at Bay Lake Tower",
+			wantPath:  "/dvc",
+			wantTitle: "2024-04-02 opened at Bay Lake Tower",
+		},
+		{
+			name:      "non-ascii title",
+			ntfy:      Ntfy{Server: server.URL + "/", Topic: "dvc"},
+			title:     "2024-04-02 opened at Animal Kingdom Villas – Jambo House",
+			wantPath:  "/dvc",
+			wantTitle: "2024-04-02 opened at Animal Kingdom Villas – Jambo House",
+		},
+		{
+			name:         "token and priority",
+			ntfy:         Ntfy{Server: server.URL, Topic: "private", Token: "tk_abc", Priority: 4},
+			title:        "Price\nchange",
+			wantPath:     "/private",
+			wantTitle:    "Price change",
+			wantAuth:     "Bearer tk_abc",
+			wantPriority: "4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := testMessage()
+			msg.Title = tt.title
+			err := tt.ntfy.Notify(context.Background(), msg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			req := <-requests
+			if req.path != tt.wantPath {
+				t.Fatalf("path %s, want %s", req.path, tt.wantPath)
+			}
+			raw := req.header.Get("Title")
+			for _, r := range raw {
+				if r > unicode.MaxASCII {
+					t.Fatalf("title header %q isn't ASCII", raw)
+				}
+			}
+			title, err := (&mime.WordDecoder{}).DecodeHeader(raw)
+			if err != nil {
+				t.Fatalf("failed to decode title %q: %v", raw, err)
+			}
+			if title != tt.wantTitle {
+				t.Fatalf("title %q, want %q", title, tt.wantTitle)
+			}
+			if got := req.header.Get("Authorization"); got != tt.wantAuth {
+				t.Fatalf("authorization %q, want %q", got, tt.wantAuth)
+			}
+			if got := req.header.Get("Priority"); got != tt.wantPriority {
+				t.Fatalf("priority %q, want %q", got, tt.wantPriority)
+			}
+			if got := req.header.Get("Tags"); got != KindAvailability {
+				t.Fatalf("tags %q", got)
+			}
+			if req.body != msg.Body {
+				t.Fatalf("body %q, want %q", req.body, msg.Body)
+			}
+		})
+	}
+}
+
+func TestNtfyNoTopic(t *testing.T) {
+	err := Ntfy{}.Notify(context.Background(), testMessage())
+	if err == nil || !strings.Contains(err.Error(), "no topic") {
+		t.Fatalf("expected missing topic error, got %v", err)
+	}
+}
+
+func TestGotify(t *testing.T) {
+	server, requests := startTestServer(t, http.StatusOK, "{}")
+
+	err := Gotify{Server: server.URL + "/", Token: "app-token", Priority: 5}.Notify(context.Background(), testMessage())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := <-requests
+	if req.path != "/message" {
+		t.Fatalf("path %s, want /message", req.path)
+	}
+	if req.header.Get("X-Gotify-Key") != "app-token" || req.header.Get("Content-Type") != "application/json" {
+		t.Fatalf("headers %v", req.header)
+	}
+	want := `{"title":"2024-04-02 opened at Bay Lake Tower","message":"Deluxe Studio on 2024-04-02 is available.","priority":5}`
+	if req.body != want {
+		t.Fatalf("got %s, want %s", req.body, want)
+	}
+}
+
+func TestGotifyErrors(t *testing.T) {
+	server, _ := startTestServer(t, http.StatusUnauthorized, `{"error":"Unauthorized"}`)
+
+	err := Gotify{}.Notify(context.Background(), testMessage())
+	if err == nil || !strings.Contains(err.Error(), "no server") {
+		t.Fatalf("expected missing server error, got %v", err)
+	}
+
+	err = Gotify{Server: server.URL, Token: "wrong"}.Notify(context.Background(), testMessage())
+	if err == nil || !strings.Contains(err.Error(), "returned 401") {
+		t.Fatalf("expected 401 error, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+)
+
+// maxErrorBody limits how much of a failed response is kept in the error
+const maxErrorBody = 512
+
+// Webhook POSTs each Message as JSON to URL
+type Webhook struct {
+	URL     string
+	Headers map[string]string
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+	// Template replaces the Message body before it's sent
+	Template *template.Template
+}
+
+// Notify sends msg
+func (w Webhook) Notify(ctx context.Context, msg Message) error {
+	body, err := render(w.Template, msg)
+	if err != nil {
+		return err
+	}
+	msg.Body = body
+
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		err = fmt.Errorf("failed to marshal message: %w", err)
+		return err
+	}
+
+	headers := map[string]string{"Content-Type": "application/json"}
+	for k, v := range w.Headers {
+		headers[k] = v
+	}
+
+	return post(ctx, w.Client, w.URL, headers, raw)
+}
+
+// Slack posts each Message to a Slack-compatible incoming webhook
+type Slack struct {
+	WebhookURL string
+	// Channel and Username override the webhook defaults when set
+	Channel  string
+	Username string
+	Client   *http.Client
+	// Template renders the message text. Defaults to the title in bold
+	// followed by the body.
+	Template *template.Template
+}
+
+var defaultSlackTemplate = template.Must(template.New("slack").Parse("*{{.Title}}*\n{{.Body}}"))
+
+// Notify sends msg
+func (s Slack) Notify(ctx context.Context, msg Message) error {
+	tmpl := s.Template
+	if tmpl == nil {
+		tmpl = defaultSlackTemplate
+	}
+
+	text, err := render(tmpl, msg)
+	if err != nil {
+		return err
+	}
+
+	payload := struct {
+		Text     string `json:"text"`
+		Channel  string `json:"channel,omitempty"`
+		Username string `json:"username,omitempty"`
+	}{text, s.Channel, s.Username}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		err = fmt.Errorf("failed to marshal slack payload: %w", err)
+		return err
+	}
+
+	return post(ctx, s.Client, s.WebhookURL, map[string]string{"Content-Type": "application/json"}, raw)
+}
+
+// post sends body to url and fails on any non-2xx response
+func post(ctx context.Context, client *http.Client, url string, headers map[string]string, body []byte) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		err = fmt.Errorf("failed to build request: %w", err)
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		err = fmt.Errorf("failed to post to %s: %w", url, err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("post to %s returned %d: %s", url, resp.StatusCode, bytes.TrimSpace(raw))
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
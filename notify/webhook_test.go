@@ -0,0 +1,152 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+// request is what a test server received
+type request struct {
+	method string
+	path   string
+	header http.Header
+	body   string
+}
+
+// startTestServer records each request and answers with status and reply
+func startTestServer(t *testing.T, status int, reply string) (*httptest.Server, <-chan request) {
+	t.Helper()
+
+	requests := make(chan request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{method: r.Method, path: r.URL.Path, header: r.Header, body: string(body)}
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, reply)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, requests
+}
+
+func testMessage() Message {
+	return Message{
+		Kind:  KindAvailability,
+		Title: "2024-04-02 opened at Bay Lake Tower",
+		Body:  "Deluxe Studio on 2024-04-02 is available.",
+		Key:   "opened/BLT/4O/2024-04-02",
+		Time:  time.Date(2024, time.April, 1, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	server, requests := startTestServer(t, http.StatusNoContent, "")
+
+	webhook := Webhook{
+		URL:      server.URL + "/hook",
+		Headers:  map[string]string{"Authorization": "Bearer abc"},
+		Template: template.Must(template.New("body").Parse("{{.Kind}}: {{.Body}}")),
+	}
+	err := webhook.Notify(context.Background(), testMessage())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := <-requests
+	if req.method != http.MethodPost || req.path != "/hook" {
+		t.Fatalf("got %s %s, want POST /hook", req.method, req.path)
+	}
+	if req.header.Get("Content-Type") != "application/json" || req.header.Get("Authorization") != "Bearer abc" {
+		t.Fatalf("headers %v", req.header)
+	}
+
+	got := Message{}
+	err = json.Unmarshal([]byte(req.body), &got)
+	if err != nil {
+		t.Fatalf("failed to decode body %s: %v", req.body, err)
+	}
+	want := testMessage()
+	want.Body = "availability: " + want.Body
+	if got.Title != want.Title || got.Body != want.Body || got.Key != want.Key || !got.Time.Equal(want.Time) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestWebhookErrorStatus(t *testing.T) {
+	server, _ := startTestServer(t, http.StatusBadGateway, "upstream down "+strings.Repeat("x", 2*maxErrorBody))
+
+	err := Webhook{URL: server.URL}.Notify(context.Background(), testMessage())
+	if err == nil || !strings.Contains(err.Error(), "returned 502: upstream down") {
+		t.Fatalf("expected error with status and body, got %v", err)
+	}
+	if len(err.Error()) > 2*maxErrorBody {
+		t.Fatalf("error kept the whole response body: %d bytes", len(err.Error()))
+	}
+}
+
+func TestSlack(t *testing.T) {
+	server, requests := startTestServer(t, http.StatusOK, "ok")
+
+	tests := []struct {
+		name  string
+		slack Slack
+		want  string
+	}{
+		{
+			name:  "default template",
+			slack: Slack{WebhookURL: server.URL},
+			want:  `{"text":"*2024-04-02 opened at Bay Lake Tower*\nDeluxe Studio on 2024-04-02 is available."}`,
+		},
+		{
+			name: "channel username and template",
+			slack: Slack{
+				WebhookURL: server.URL,
+				Channel:    "#dvc",
+				Username:   "dvc-scraper",
+				Template:   template.Must(template.New("slack").Parse("{{.Title}}")),
+			},
+			want: `{"text":"2024-04-02 opened at Bay Lake Tower","channel":"#dvc","username":"dvc-scraper"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.slack.Notify(context.Background(), testMessage())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			req := <-requests
+			if req.header.Get("Content-Type") != "application/json" {
+				t.Fatalf("content type %q", req.header.Get("Content-Type"))
+			}
+			if req.body != tt.want {
+				t.Fatalf("got %s, want %s", req.body, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostCancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := Webhook{URL: server.URL}.Notify(ctx, testMessage())
+	if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+package dvcscraper
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+const (
+	defaultOTPPollInterval = 10 * time.Second
+	defaultOTPSender       = "disney"
+	defaultOTPMailbox      = "INBOX"
+	// otpClockSkew allows for the mail server's clock being a little behind
+	otpClockSkew = time.Minute
+)
+
+// defaultOTPPattern finds a six digit code that isn't part of a longer word or
+// a color like #000000
+var defaultOTPPattern = regexp.MustCompile(`(?:^|[^#\w])(\d{6})(?:\W|$)`)
+
+// OTPProvider supplies the one-time passcode Disney ID sends during login.
+//
+// OTP is called once the passcode has been requested and should return when
+// the code is known or ctx is done.
+type OTPProvider interface {
+	OTP(ctx context.Context, email string) (string, error)
+}
+
+// OTPProviderFunc adapts a function to an OTPProvider
+type OTPProviderFunc func(ctx context.Context, email string) (string, error)
+
+// OTP calls f
+func (f OTPProviderFunc) OTP(ctx context.Context, email string) (string, error) {
+	return f(ctx, email)
+}
+
+// StaticOTP returns an OTPProvider that always returns code. It is intended
+// for tests.
+func StaticOTP(code string) OTPProvider {
+	return OTPProviderFunc(func(context.Context, string) (string, error) {
+		return code, nil
+	})
+}
+
+// PromptOTP asks for the passcode interactively
+type PromptOTP struct {
+	In  io.Reader
+	Out io.Writer
+
+	mu     sync.Mutex
+	reader *bufio.Reader
+	// pending is a read left running by a prompt that gave up waiting; the
+	// next prompt takes its line rather than racing it for input
+	pending chan promptLine
+}
+
+type promptLine struct {
+	line string
+	err  error
+}
+
+// NewStdinOTP returns a PromptOTP reading from stdin and prompting on stderr
+func NewStdinOTP() *PromptOTP {
+	return &PromptOTP{In: os.Stdin, Out: os.Stderr}
+}
+
+// OTP prompts for the code and reads one line
+func (p *PromptOTP) OTP(ctx context.Context, email string) (string, error) {
+	fmt.Fprintf(p.Out, "Enter the one-time passcode sent to %s: ", email)
+
+	p.mu.Lock()
+	if p.reader == nil {
+		p.reader = bufio.NewReader(p.In)
+	}
+	lines := p.pending
+	p.pending = nil
+	if lines == nil {
+		lines = make(chan promptLine, 1)
+		go func(reader *bufio.Reader) {
+			line, err := reader.ReadString('\n')
+			if errors.Is(err, io.EOF) && line != "" {
+				err = nil
+			}
+			lines <- promptLine{line: line, err: err}
+		}(p.reader)
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		p.mu.Lock()
+		p.pending = lines
+		p.mu.Unlock()
+		return "", ctx.Err()
+	case res := <-lines:
+		if res.err != nil {
+			err := fmt.Errorf("failed to read passcode: %w", res.err)
+			return "", err
+		}
+		return strings.TrimSpace(res.line), nil
+	}
+}
+
+// IMAPOTP polls an IMAP mailbox for the passcode email
+type IMAPOTP struct {
+	// Addr is the host:port of the IMAP server
+	Addr     string
+	Username string
+	Password string
+
+	// Mailbox defaults to INBOX
+	Mailbox string
+	// From matches the sender of passcode emails. Defaults to "disney".
+	From string
+	// PollInterval defaults to 10 seconds
+	PollInterval time.Duration
+	// Pattern finds the code in the email; its first group is the code.
+	// Defaults to the first standalone six digit number.
+	Pattern *regexp.Regexp
+
+	// TLSConfig is used when connecting unless Insecure is set
+	TLSConfig *tls.Config
+	// Insecure connects without TLS, e.g. to a local test server
+	Insecure bool
+}
+
+// OTP polls until a passcode email that arrived after the call is found
+func (i *IMAPOTP) OTP(ctx context.Context, email string) (string, error) {
+	requested := time.Now().Add(-otpClockSkew)
+
+	interval := i.PollInterval
+	if interval == 0 {
+		interval = defaultOTPPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		code, err := i.check(requested)
+		if err != nil {
+			return "", err
+		}
+		if code != "" {
+			return code, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (i *IMAPOTP) check(since time.Time) (string, error) {
+	var c *client.Client
+	var err error
+	if i.Insecure {
+		c, err = client.Dial(i.Addr)
+	} else {
+		c, err = client.DialTLS(i.Addr, i.TLSConfig)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to connect to IMAP server: %w", err)
+		return "", err
+	}
+	defer c.Logout()
+
+	err = c.Login(i.Username, i.Password)
+	if err != nil {
+		err = fmt.Errorf("failed to log in to IMAP server: %w", err)
+		return "", err
+	}
+
+	mailbox := i.Mailbox
+	if mailbox == "" {
+		mailbox = defaultOTPMailbox
+	}
+	_, err = c.Select(mailbox, true)
+	if err != nil {
+		err = fmt.Errorf("failed to select mailbox '%s': %w", mailbox, err)
+		return "", err
+	}
+
+	from := i.From
+	if from == "" {
+		from = defaultOTPSender
+	}
+	criteria := imap.NewSearchCriteria()
+	criteria.Since = since
+	criteria.Header.Add("From", from)
+	ids, err := c.Search(criteria)
+	if err != nil {
+		err = fmt.Errorf("failed to search mailbox: %w", err)
+		return "", err
+	}
+	if len(ids) == 0 {
+		return "", nil
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(ids...)
+	section := &imap.BodySectionName{Peek: true}
+	messages := make(chan *imap.Message, len(ids))
+	err = c.Fetch(seqSet, []imap.FetchItem{imap.FetchInternalDate, section.FetchItem()}, messages)
+	if err != nil {
+		err = fmt.Errorf("failed to fetch messages: %w", err)
+		return "", err
+	}
+
+	pattern := i.Pattern
+	if pattern == nil {
+		pattern = defaultOTPPattern
+	}
+
+	var newest time.Time
+	code := ""
+	for msg := range messages {
+		if msg.InternalDate.Before(since) || msg.InternalDate.Before(newest) {
+			continue
+		}
+
+		body := msg.GetBody(section)
+		if body == nil {
+			continue
+		}
+
+		text, err := messageText(body)
+		if err != nil {
+			err = fmt.Errorf("failed to read passcode email: %w", err)
+			return "", err
+		}
+
+		match := pattern.FindStringSubmatch(text)
+		if len(match) < 2 {
+			continue
+		}
+
+		newest = msg.InternalDate
+		code = match[1]
+	}
+
+	return code, nil
+}
+
+// messageText returns the decoded text of every part of an email
+func messageText(r io.Reader) (string, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		err = fmt.Errorf("failed to parse email: %w", err)
+		return "", err
+	}
+
+	return partText(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+}
+
+func partText(contentType, encoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err == nil && strings.HasPrefix(mediaType, "multipart/") {
+		parts := multipart.NewReader(body, params["boundary"])
+		texts := []string{}
+		for {
+			part, err := parts.NextRawPart()
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				err = fmt.Errorf("failed to read email part: %w", err)
+				return "", err
+			}
+
+			text, err := partText(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return "", err
+			}
+			texts = append(texts, text)
+		}
+		return strings.Join(texts, "\n"), nil
+	}
+
+	switch strings.ToLower(encoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		err = fmt.Errorf("failed to read email body: %w", err)
+		return "", err
+	}
+
+	return string(raw), nil
+}
@@ -0,0 +1,409 @@
+package dvcscraper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend"
+	"github.com/emersion/go-imap/server"
+)
+
+func TestPromptOTPKeepsBufferedInput(t *testing.T) {
+	prompt := &PromptOTP{
+		In:  strings.NewReader("111111\n 222222 \n333333"),
+		Out: io.Discard,
+	}
+
+	for _, want := range []string{"111111", "222222", "333333"} {
+		got, err := prompt.OTP(context.Background(), "member@example.com")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestPromptOTPResumesAbandonedRead(t *testing.T) {
+	in, typed := io.Pipe()
+	prompt := &PromptOTP{In: in, Out: io.Discard}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := prompt.OTP(ctx, "member@example.com")
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	go typed.Write([]byte("444444\n"))
+
+	got, err := prompt.OTP(context.Background(), "member@example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "444444" {
+		t.Fatalf("got %q, want %q", got, "444444")
+	}
+}
+
+func TestDefaultOTPPattern(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "sentence", text: "Your one-time passcode is 123456.", want: "123456"},
+		{name: "own line", text: "Passcode:\n654321\nIt expires in 15 minutes", want: "654321"},
+		{name: "start and end", text: "246810", want: "246810"},
+		{name: "color skipped", text: "<td style=\"color:#000000\">135791</td>", want: "135791"},
+		{name: "longer number", text: "Order 1234567", want: ""},
+		{name: "part of a word", text: "ref abc123456", want: ""},
+		{name: "five digits", text: "code 12345", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if match := defaultOTPPattern.FindStringSubmatch(tt.text); len(match) > 1 {
+				got = match[1]
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageText(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{
+			name: "plain",
+			raw:  "From: a@disney.com\r\nSubject: code\r\n\r\nYour code is 123456",
+			want: "Your code is 123456",
+		},
+		{
+			name: "quoted-printable",
+			raw: "From: a@disney.com\r\nContent-Type: text/html; charset=utf-8\r\n" +
+				"Content-Transfer-Encoding: quoted-printable\r\n\r\n" +
+				"<p style=3D\"x\">Your code is 123456</p>=\r\n<p>bye</p>",
+			want: "<p style=\"x\">Your code is 123456</p><p>bye</p>",
+		},
+		{
+			name: "base64",
+			raw: "From: a@disney.com\r\nContent-Transfer-Encoding: base64\r\n\r\n" +
+				"WW91ciBjb2RlIGlzIDEyMzQ1Ng==",
+			want: "Your code is 123456",
+		},
+		{
+			name: "nested multipart",
+			raw: "From: a@disney.com\r\nContent-Type: multipart/mixed; boundary=outer\r\n\r\n" +
+				"--outer\r\nContent-Type: multipart/alternative; boundary=inner\r\n\r\n" +
+				"--inner\r\nContent-Type: text/plain\r\n\r\nplain 123456\r\n" +
+				"--inner\r\nContent-Type: text/html\r\nContent-Transfer-Encoding: base64\r\n\r\n" +
+				"PGI+MTIzNDU2PC9iPg==\r\n--inner--\r\n" +
+				"--outer\r\nContent-Type: text/plain\r\n\r\nfooter\r\n--outer--\r\n",
+			want: "plain 123456\n<b>123456</b>\nfooter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := messageText(strings.NewReader(tt.raw))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIMAPOTP(t *testing.T) {
+	now := time.Now()
+	email := func(from, body string) string {
+		return "From: " + from + "\r\nSubject: Your passcode\r\n\r\n" + body
+	}
+
+	tests := []struct {
+		name     string
+		messages []testIMAPMessage
+		password string
+		want     string
+		wantErr  string
+	}{
+		{
+			name: "newest passcode",
+			messages: []testIMAPMessage{
+				{date: now.Add(-30 * time.Second), raw: email("Disney <noreply@disney.com>", "Your code is 111111")},
+				{date: now.Add(-10 * time.Second), raw: email("Disney <noreply@disney.com>", "Your code is 222222")},
+				{date: now.Add(-20 * time.Second), raw: email("Disney <noreply@disney.com>", "Your code is 333333")},
+			},
+			want: "222222",
+		},
+		{
+			name: "other senders ignored",
+			messages: []testIMAPMessage{
+				{date: now.Add(-10 * time.Second), raw: email("bank@example.com", "Your code is 999999")},
+				{date: now.Add(-20 * time.Second), raw: email("Disney <noreply@disney.com>", "Your code is 444444")},
+			},
+			want: "444444",
+		},
+		{
+			name: "emails from before the request ignored",
+			messages: []testIMAPMessage{
+				{date: now.Add(-2 * time.Hour), raw: email("Disney <noreply@disney.com>", "Your code is 555555")},
+				{date: now.Add(-10 * time.Second), raw: email("Disney <noreply@disney.com>", "Your code is 666666")},
+			},
+			want: "666666",
+		},
+		{
+			name:     "bad login",
+			password: "wrong",
+			wantErr:  "failed to log in",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mailbox := &testIMAPMailbox{messages: tt.messages}
+			addr := startTestIMAPServer(t, mailbox)
+
+			password := tt.password
+			if password == "" {
+				password = "secret"
+			}
+			provider := &IMAPOTP{Addr: addr, Username: "member", Password: password, Insecure: true}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			got, err := provider.OTP(ctx, "member@example.com")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIMAPOTPPollsUntilDelivered(t *testing.T) {
+	mailbox := &testIMAPMailbox{}
+	addr := startTestIMAPServer(t, mailbox)
+	provider := &IMAPOTP{Addr: addr, Username: "member", Password: "secret", Insecure: true, PollInterval: 20 * time.Millisecond}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		mailbox.deliver(testIMAPMessage{date: time.Now(), raw: "From: noreply@disney.com\r\n\r\nYour code is 777777"})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got, err := provider.OTP(ctx, "member@example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "777777" {
+		t.Fatalf("got %q, want 777777", got)
+	}
+}
+
+func TestIMAPOTPGivesUpWithContext(t *testing.T) {
+	addr := startTestIMAPServer(t, &testIMAPMailbox{})
+	provider := &IMAPOTP{Addr: addr, Username: "member", Password: "secret", Insecure: true, PollInterval: 20 * time.Millisecond}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := provider.OTP(ctx, "member@example.com")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// startTestIMAPServer serves mailbox as the INBOX of user "member" with
+// password "secret" on a local port, returning its address
+func startTestIMAPServer(t *testing.T, mailbox *testIMAPMailbox) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	s := server.New(testIMAPBackend{mailbox: mailbox})
+	s.AllowInsecureAuth = true
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+
+	return l.Addr().String()
+}
+
+type testIMAPMessage struct {
+	date time.Time
+	raw  string
+}
+
+type testIMAPBackend struct {
+	mailbox *testIMAPMailbox
+}
+
+func (b testIMAPBackend) Login(_ *imap.ConnInfo, username, password string) (backend.User, error) {
+	if username != "member" || password != "secret" {
+		return nil, backend.ErrInvalidCredentials
+	}
+	return testIMAPUser{mailbox: b.mailbox}, nil
+}
+
+type testIMAPUser struct {
+	mailbox *testIMAPMailbox
+}
+
+func (u testIMAPUser) Username() string { return "member" }
+
+func (u testIMAPUser) ListMailboxes(bool) ([]backend.Mailbox, error) {
+	return []backend.Mailbox{u.mailbox}, nil
+}
+
+func (u testIMAPUser) GetMailbox(name string) (backend.Mailbox, error) {
+	if name != "INBOX" {
+		return nil, backend.ErrNoSuchMailbox
+	}
+	return u.mailbox, nil
+}
+
+func (u testIMAPUser) CreateMailbox(string) error         { return errors.New("not supported") }
+func (u testIMAPUser) DeleteMailbox(string) error         { return errors.New("not supported") }
+func (u testIMAPUser) RenameMailbox(string, string) error { return errors.New("not supported") }
+func (u testIMAPUser) Logout() error                      { return nil }
+
+// testIMAPMailbox is a read-only INBOX supporting the FROM and SINCE searches
+// and the fetches IMAPOTP makes
+type testIMAPMailbox struct {
+	mu       sync.Mutex
+	messages []testIMAPMessage
+}
+
+func (m *testIMAPMailbox) deliver(msg testIMAPMessage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+}
+
+func (m *testIMAPMailbox) Name() string { return "INBOX" }
+
+func (m *testIMAPMailbox) Info() (*imap.MailboxInfo, error) {
+	return &imap.MailboxInfo{Delimiter: "/", Name: "INBOX"}, nil
+}
+
+func (m *testIMAPMailbox) Status(items []imap.StatusItem) (*imap.MailboxStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := imap.NewMailboxStatus("INBOX", items)
+	status.PermanentFlags = []string{}
+	for _, item := range items {
+		switch item {
+		case imap.StatusMessages:
+			status.Messages = uint32(len(m.messages))
+		case imap.StatusUidNext:
+			status.UidNext = uint32(len(m.messages) + 1)
+		case imap.StatusUidValidity:
+			status.UidValidity = 1
+		}
+	}
+	return status, nil
+}
+
+func (m *testIMAPMailbox) SetSubscribed(bool) error { return nil }
+func (m *testIMAPMailbox) Check() error             { return nil }
+
+func (m *testIMAPMailbox) ListMessages(_ bool, seqSet *imap.SeqSet, items []imap.FetchItem, ch chan<- *imap.Message) error {
+	defer close(ch)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, stored := range m.messages {
+		seqNum := uint32(i + 1)
+		if !seqSet.Contains(seqNum) {
+			continue
+		}
+
+		msg := imap.NewMessage(seqNum, items)
+		for _, item := range items {
+			if item == imap.FetchInternalDate {
+				msg.InternalDate = stored.date
+				continue
+			}
+			section, err := imap.ParseBodySectionName(item)
+			if err != nil {
+				continue
+			}
+			msg.Body[section] = bytes.NewBufferString(stored.raw)
+		}
+		ch <- msg
+	}
+	return nil
+}
+
+func (m *testIMAPMailbox) SearchMessages(_ bool, criteria *imap.SearchCriteria) ([]uint32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := []uint32{}
+	for i, stored := range m.messages {
+		// SINCE compares dates, not times
+		y, mo, d := criteria.Since.Date()
+		if stored.date.Before(time.Date(y, mo, d, 0, 0, 0, 0, criteria.Since.Location())) {
+			continue
+		}
+
+		parsed, err := mail.ReadMessage(strings.NewReader(stored.raw))
+		if err != nil {
+			return nil, err
+		}
+		from := strings.ToLower(criteria.Header.Get("From"))
+		if !strings.Contains(strings.ToLower(parsed.Header.Get("From")), from) {
+			continue
+		}
+
+		ids = append(ids, uint32(i+1))
+	}
+	return ids, nil
+}
+
+func (m *testIMAPMailbox) CreateMessage([]string, time.Time, imap.Literal) error {
+	return errors.New("read-only mailbox")
+}
+
+func (m *testIMAPMailbox) UpdateMessagesFlags(bool, *imap.SeqSet, imap.FlagsOp, []string) error {
+	return errors.New("read-only mailbox")
+}
+
+func (m *testIMAPMailbox) CopyMessages(bool, *imap.SeqSet, string) error {
+	return errors.New("read-only mailbox")
+}
+
+func (m *testIMAPMailbox) Expunge() error {
+	return errors.New("read-only mailbox")
+}
@@ -0,0 +1,339 @@
+// Package output encodes scraper results as JSON, NDJSON, CSV, TSV, terminal
+// tables or Markdown with a fixed column order per kind of result.
+//
+// Machine-readable formats carry a schema version. It's bumped whenever a
+// column or field is renamed, removed or reordered, so scripts can detect
+// changes they don't understand.
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+)
+
+// SchemaVersion of every format's fields and columns
+const SchemaVersion = 1
+
+// Format is an output encoding
+type Format string
+
+// Supported formats
+const (
+	JSON     Format = "json"
+	NDJSON   Format = "ndjson"
+	CSV      Format = "csv"
+	TSV      Format = "tsv"
+	Table    Format = "table"
+	Markdown Format = "markdown"
+)
+
+// Formats lists every supported format
+func Formats() []Format {
+	return []Format{JSON, NDJSON, CSV, TSV, Table, Markdown}
+}
+
+// ParseFormat returns the Format named by name, ignoring case
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats() {
+		if strings.EqualFold(string(format), name) {
+			return format, nil
+		}
+	}
+
+	names := []string{}
+	for _, format := range Formats() {
+		names = append(names, string(format))
+	}
+	return "", fmt.Errorf("unknown format '%s': want one of %s", name, strings.Join(names, ", "))
+}
+
+// Dataset is a set of results ready to encode in any Format
+type Dataset struct {
+	// Kind names the results, e.g. "availability"
+	Kind string
+	// Columns are the CSV, TSV, table and Markdown headings, in order
+	Columns []string
+
+	rows [][]string
+	// records are written one per line by NDJSON
+	records []interface{}
+	// data is the JSON document's payload
+	data interface{}
+}
+
+// document is the top level of JSON output
+type document struct {
+	SchemaVersion int         `json:"schemaVersion"`
+	Kind          string      `json:"kind"`
+	Data          interface{} `json:"data"`
+}
+
+// Write encodes d to w in format
+func Write(w io.Writer, format Format, d Dataset) error {
+	var err error
+	switch format {
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(document{SchemaVersion: SchemaVersion, Kind: d.Kind, Data: d.data})
+	case NDJSON:
+		err = writeNDJSON(w, d)
+	case CSV:
+		err = writeCSV(w, d)
+	case TSV:
+		err = writeTSV(w, d)
+	case Table:
+		err = writeTable(w, d)
+	case Markdown:
+		err = writeMarkdown(w, d)
+	default:
+		err = fmt.Errorf("unknown format '%s'", format)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("failed to write %s %s: %w", d.Kind, format, err)
+		return err
+	}
+	return nil
+}
+
+func writeNDJSON(w io.Writer, d Dataset) error {
+	enc := json.NewEncoder(w)
+	for _, record := range d.records {
+		err := enc.Encode(record)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCSV leads every row with the schema version so each line stands alone
+func writeCSV(w io.Writer, d Dataset) error {
+	version := strconv.Itoa(SchemaVersion)
+
+	cw := csv.NewWriter(w)
+	err := cw.Write(append([]string{"schema_version"}, d.Columns...))
+	if err != nil {
+		return err
+	}
+	for _, row := range d.rows {
+		err = cw.Write(append([]string{version}, row...))
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeTSV is writeCSV with tabs, and without quoting: tabs and newlines in
+// values become spaces
+func writeTSV(w io.Writer, d Dataset) error {
+	version := strconv.Itoa(SchemaVersion)
+	clean := strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+
+	lines := [][]string{append([]string{"schema_version"}, d.Columns...)}
+	for _, row := range d.rows {
+		lines = append(lines, append([]string{version}, row...))
+	}
+
+	for _, line := range lines {
+		values := make([]string, 0, len(line))
+		for _, value := range line {
+			values = append(values, clean.Replace(value))
+		}
+		_, err := fmt.Fprintln(w, strings.Join(values, "\t"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTable(w io.Writer, d Dataset) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	headings := make([]string, 0, len(d.Columns))
+	for _, column := range d.Columns {
+		headings = append(headings, strings.ToUpper(strings.ReplaceAll(column, "_", " ")))
+	}
+	fmt.Fprintln(tw, strings.Join(headings, "\t"))
+
+	clean := strings.NewReplacer("\t", " ", "\n", " ")
+	for _, row := range d.rows {
+		values := make([]string, 0, len(row))
+		for _, value := range row {
+			values = append(values, clean.Replace(value))
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+func writeMarkdown(w io.Writer, d Dataset) error {
+	clean := strings.NewReplacer("|", "\\|", "\r", " ", "\n", " ")
+
+	line := func(values []string) error {
+		cells := make([]string, 0, len(values))
+		for _, value := range values {
+			cells = append(cells, clean.Replace(value))
+		}
+		_, err := fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+		return err
+	}
+
+	err := line(d.Columns)
+	if err != nil {
+		return err
+	}
+
+	rule := make([]string, len(d.Columns))
+	for i := range rule {
+		rule[i] = "---"
+	}
+	err = line(rule)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range d.rows {
+		err = line(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nightRecord is an NDJSON line of availability
+type nightRecord struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Resort        string `json:"resort"`
+	RoomType      string `json:"roomType"`
+	Date          string `json:"date"`
+	Rooms         int    `json:"rooms"`
+	Points        int    `json:"points"`
+}
+
+// Availability returns the nights of every result, one row per night
+func Availability(results ...dvcscraper.AvailabilityResults) Dataset {
+	if results == nil {
+		results = []dvcscraper.AvailabilityResults{}
+	}
+
+	d := Dataset{
+		Kind:    "availability",
+		Columns: []string{"resort", "room_type", "date", "rooms", "points"},
+		data:    results,
+	}
+
+	for _, result := range results {
+		for _, night := range result.Availability {
+			d.rows = append(d.rows, []string{
+				string(result.ResortCode),
+				string(result.RoomCode),
+				night.Day(),
+				strconv.Itoa(night.Rooms),
+				strconv.Itoa(night.Points),
+			})
+			d.records = append(d.records, nightRecord{
+				SchemaVersion: SchemaVersion,
+				Resort:        string(result.ResortCode),
+				RoomType:      string(result.RoomCode),
+				Date:          night.Day(),
+				Rooms:         night.Rooms,
+				Points:        night.Points,
+			})
+		}
+	}
+
+	return d
+}
+
+// priceRecord is an NDJSON line of prices
+type priceRecord struct {
+	SchemaVersion int     `json:"schemaVersion"`
+	Resort        string  `json:"resort"`
+	Name          string  `json:"name"`
+	PricePerPoint float64 `json:"price_per_point"`
+}
+
+// Prices returns one row per resort
+func Prices(prices []dvcscraper.ResortPrice) Dataset {
+	if prices == nil {
+		prices = []dvcscraper.ResortPrice{}
+	}
+
+	d := Dataset{
+		Kind:    "prices",
+		Columns: []string{"resort", "name", "price_per_point"},
+		data:    prices,
+	}
+
+	for _, price := range prices {
+		name := strings.Join(strings.Fields(price.Name), " ")
+		d.rows = append(d.rows, []string{
+			string(price.Resort),
+			name,
+			strconv.FormatFloat(price.PricePerPoint, 'f', 2, 64),
+		})
+		d.records = append(d.records, priceRecord{
+			SchemaVersion: SchemaVersion,
+			Resort:        string(price.Resort),
+			Name:          name,
+			PricePerPoint: price.PricePerPoint,
+		})
+	}
+
+	return d
+}
+
+// stayRecord is an NDJSON line of stays
+type stayRecord struct {
+	SchemaVersion int `json:"schemaVersion"`
+	dvcscraper.Stay
+	Split bool `json:"split"`
+}
+
+// Stays returns one row per stay. The segments column describes each room
+// type as "RESORT ROOM CHECKIN/CHECKOUT", joined by " + " for split stays.
+func Stays(stays []dvcscraper.Stay) Dataset {
+	if stays == nil {
+		stays = []dvcscraper.Stay{}
+	}
+
+	d := Dataset{
+		Kind:    "stays",
+		Columns: []string{"check_in", "check_out", "nights", "points", "split", "segments"},
+		data:    stays,
+	}
+
+	for _, stay := range stays {
+		segments := make([]string, 0, len(stay.Segments))
+		for _, segment := range stay.Segments {
+			segments = append(segments, fmt.Sprintf("%s %s %s/%s", segment.Resort, segment.RoomType, segment.CheckIn, segment.CheckOut))
+		}
+
+		d.rows = append(d.rows, []string{
+			stay.CheckIn,
+			stay.CheckOut,
+			strconv.Itoa(stay.Nights),
+			strconv.Itoa(stay.Points),
+			strconv.FormatBool(stay.Split()),
+			strings.Join(segments, " + "),
+		})
+		d.records = append(d.records, stayRecord{SchemaVersion: SchemaVersion, Stay: stay, Split: stay.Split()})
+	}
+
+	return d
+}
@@ -0,0 +1,165 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+)
+
+func testAvailability() Dataset {
+	return Availability(dvcscraper.AvailabilityResults{
+		ResortCode: "BLT",
+		RoomCode:   "4O",
+		Availability: []dvcscraper.DayAvailability{
+			{Date: "2024-04-05T00:00:00", Rooms: 1, Points: 20},
+			{Date: "2024-04-06", Rooms: 0, Points: 24},
+		},
+	})
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		dataset Dataset
+		want    string
+	}{
+		{
+			name:    "csv",
+			format:  CSV,
+			dataset: testAvailability(),
+			want: "schema_version,resort,room_type,date,rooms,points\n" +
+				"1,BLT,4O,2024-04-05,1,20\n" +
+				"1,BLT,4O,2024-04-06,0,24\n",
+		},
+		{
+			name:    "tsv",
+			format:  TSV,
+			dataset: testAvailability(),
+			want: "schema_version\tresort\troom_type\tdate\trooms\tpoints\n" +
+				"1\tBLT\t4O\t2024-04-05\t1\t20\n" +
+				"1\tBLT\t4O\t2024-04-06\t0\t24\n",
+		},
+		{
+			name:    "ndjson",
+			format:  NDJSON,
+			dataset: testAvailability(),
+			want: `{"schemaVersion":1,"resort":"BLT","roomType":"4O","date":"2024-04-05","rooms":1,"points":20}` + "\n" +
+				`{"schemaVersion":1,"resort":"BLT","roomType":"4O","date":"2024-04-06","rooms":0,"points":24}` + "\n",
+		},
+		{
+			name:    "table",
+			format:  Table,
+			dataset: testAvailability(),
+			want: "RESORT  ROOM TYPE  DATE        ROOMS  POINTS\n" +
+				"BLT     4O         2024-04-05  1      20\n" +
+				"BLT     4O         2024-04-06  0      24\n",
+		},
+		{
+			name:    "markdown escapes pipes and newlines",
+			format:  Markdown,
+			dataset: Prices([]dvcscraper.ResortPrice{{Name: "Bay|Lake\nTower", Resort: "BLT", PricePerPoint: 215.5}}),
+			want: "| resort | name | price_per_point |\n" +
+				"| --- | --- | --- |\n" +
+				"| BLT | Bay\\|Lake Tower | 215.50 |\n",
+		},
+		{
+			name:    "tsv replaces tabs",
+			format:  TSV,
+			dataset: Prices([]dvcscraper.ResortPrice{{Name: "Bay\tLake", Resort: "BLT", PricePerPoint: 215}}),
+			want: "schema_version\tresort\tname\tprice_per_point\n" +
+				"1\tBLT\tBay Lake\t215.00\n",
+		},
+		{
+			name:   "stays",
+			format: CSV,
+			dataset: Stays([]dvcscraper.Stay{{
+				CheckIn: "2024-04-05", CheckOut: "2024-04-07", Nights: 2, Points: 40,
+				Segments: []dvcscraper.StaySegment{
+					{Resort: "BLT", RoomType: "4O", CheckIn: "2024-04-05", CheckOut: "2024-04-06"},
+					{Resort: "BLT", RoomType: "4L", CheckIn: "2024-04-06", CheckOut: "2024-04-07"},
+				},
+			}}),
+			want: "schema_version,check_in,check_out,nights,points,split,segments\n" +
+				"1,2024-04-05,2024-04-07,2,40,true,BLT 4O 2024-04-05/2024-04-06 + BLT 4L 2024-04-06/2024-04-07\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := bytes.Buffer{}
+			err := Write(&b, tt.format, tt.dataset)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if b.String() != tt.want {
+				t.Fatalf("got:\n%s\nwant:\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		dataset  Dataset
+		wantKind string
+		wantData string
+	}{
+		{name: "availability", dataset: testAvailability(), wantKind: "availability", wantData: `[{"resortCode":"BLT"`},
+		{name: "empty prices", dataset: Prices(nil), wantKind: "prices", wantData: `[]`},
+		{name: "empty stays", dataset: Stays(nil), wantKind: "stays", wantData: `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := bytes.Buffer{}
+			err := Write(&b, JSON, tt.dataset)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			doc := struct {
+				SchemaVersion int             `json:"schemaVersion"`
+				Kind          string          `json:"kind"`
+				Data          json.RawMessage `json:"data"`
+			}{}
+			err = json.Unmarshal(b.Bytes(), &doc)
+			if err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if doc.SchemaVersion != SchemaVersion || doc.Kind != tt.wantKind {
+				t.Fatalf("got version %d kind %q", doc.SchemaVersion, doc.Kind)
+			}
+			compact := bytes.Buffer{}
+			json.Compact(&compact, doc.Data)
+			if !strings.HasPrefix(compact.String(), tt.wantData) {
+				t.Fatalf("data %s doesn't start with %s", compact.String(), tt.wantData)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Format
+		wantErr bool
+	}{
+		{name: "csv", want: CSV},
+		{name: "Markdown", want: Markdown},
+		{name: "NDJSON", want: NDJSON},
+		{name: "xml", wantErr: true},
+		{name: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Fatalf("ParseFormat(%q) = %q, %v", tt.name, got, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package dvcscraper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/stealth"
+)
+
+const defaultPagePoolSize = 4
+
+// pagePool hands out stealth pages of one browser, at most size at a time.
+// Pages share the browser's cookies, so a login on any of them authenticates
+// all of them.
+type pagePool struct {
+	browser *rod.Browser
+	slots   chan struct{}
+	prepare func(*rod.Page) error
+
+	mu   sync.Mutex
+	idle []*rod.Page
+}
+
+// newPagePool returns a pool of size pages, each set up by prepare when it's
+// opened
+func newPagePool(browser *rod.Browser, size int, prepare func(*rod.Page) error) *pagePool {
+	if size <= 0 {
+		size = defaultPagePoolSize
+	}
+
+	return &pagePool{
+		browser: browser,
+		slots:   make(chan struct{}, size),
+		prepare: prepare,
+	}
+}
+
+// get checks out a page, waiting for one to be free until ctx is done
+func (p *pagePool) get(ctx context.Context) (*rod.Page, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		err := fmt.Errorf("failed to wait for a free page: %w", ctx.Err())
+		return nil, err
+	}
+
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		page := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return page, nil
+	}
+	p.mu.Unlock()
+
+	page, err := stealth.Page(p.browser)
+	if err != nil {
+		<-p.slots
+		err = fmt.Errorf("failed to open page: %w", err)
+		return nil, err
+	}
+
+	if p.prepare != nil {
+		err = p.prepare(page)
+		if err != nil {
+			_ = page.Close()
+			<-p.slots
+			err = fmt.Errorf("failed to prepare page: %w", err)
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// put returns a page checked out with get. err is the result of the page's
+// last use; a page that failed may have crashed, be stuck mid-navigation or
+// still be running a cancelled script, so it's closed instead of pooled and
+// the next get opens a fresh one.
+func (p *pagePool) put(page *rod.Page, err error) {
+	if err != nil {
+		_ = page.Close()
+		<-p.slots
+		return
+	}
+
+	p.mu.Lock()
+	p.idle = append(p.idle, page)
+	p.mu.Unlock()
+
+	<-p.slots
+}
+
+// close closes the idle pages. Pages still checked out are left open.
+func (p *pagePool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, page := range p.idle {
+		_ = page.Close()
+	}
+	p.idle = nil
+}
+
+// loginState serializes logins so that operations finding an expired session
+// at the same time log in once between them
+type loginState struct {
+	mu   sync.Mutex
+	last time.Time
+}
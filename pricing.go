@@ -1,9 +1,15 @@
 package dvcscraper
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/lineleader/dvc-scraper/catalog"
 )
 
 const (
@@ -16,26 +22,59 @@ const (
 
 // ResortPrice models a resort and a dollar per point price
 type ResortPrice struct {
-	Name          string  `json:"name"`
-	PricePerPoint float64 `json:"price_per_point"`
+	Name string `json:"name"`
+	// Resort is the catalog code matching Name, empty if it isn't recognized
+	Resort        catalog.ResortCode `json:"resort"`
+	PricePerPoint float64            `json:"price_per_point"`
 }
 
 // GetPurchasePrices returns current pricing for new contracts with DVC
 func (s *Scraper) GetPurchasePrices() ([]ResortPrice, error) {
-	prices := []ResortPrice{}
+	return s.GetPurchasePricesContext(context.Background())
+}
 
-	err := s.AuthenticatedNavigate(addOnURL, resortCardsSelector)
+// GetPurchasePricesContext is GetPurchasePrices bounded by ctx
+func (s *Scraper) GetPurchasePricesContext(ctx context.Context) ([]ResortPrice, error) {
+	var prices []ResortPrice
+	start := time.Now()
+	page, err := s.pages.get(ctx)
 	if err != nil {
-		err = fmt.Errorf("failed to visit add-on tool page: %w", err)
+		err = fmt.Errorf("failed to get page for purchase prices: %w", err)
+		s.observe(endpointPrices, start, err)
 		return prices, err
 	}
 
-	page, err := s.getPage()
+	err = s.withRetry(ctx, endpointPrices, "purchase prices", s.reauthOn(page), func() error {
+		var err error
+		prices, err = s.getPurchasePrices(ctx, page)
+		return err
+	})
+	s.pages.put(page, err)
+	s.observe(endpointPrices, start, err)
 	if err != nil {
-		err = fmt.Errorf("failed to get bypass page: %w", err)
 		return prices, err
 	}
 
+	for _, price := range prices {
+		resort := string(price.Resort)
+		if resort == "" {
+			resort = strings.Join(strings.Fields(price.Name), " ")
+		}
+		s.metrics.PricePerPoint(resort, price.PricePerPoint)
+	}
+	return prices, nil
+}
+
+func (s *Scraper) getPurchasePrices(ctx context.Context, page *rod.Page) ([]ResortPrice, error) {
+	prices := []ResortPrice{}
+
+	err := s.authenticatedNavigate(ctx, page, addOnURL, resortCardsSelector)
+	if err != nil {
+		err = fmt.Errorf("failed to visit add-on tool page: %w", err)
+		return prices, err
+	}
+	page = page.Context(ctx)
+
 	_, err = page.Race().Element(resortCardsSelector).Do()
 	if err != nil {
 		err = fmt.Errorf("failed to wait for resort cards: %w", err)
@@ -68,10 +107,14 @@ func (s *Scraper) GetPurchasePrices() ([]ResortPrice, error) {
 		trimmedPrice := priceRegExp.FindString(price)
 		parsedPrice, err := strconv.ParseFloat(trimmedPrice, 64)
 		if err != nil {
-			err = fmt.Errorf("failed to parse price (%s => %s): %w", price, trimmedPrice, err)
+			err = fmt.Errorf("failed to parse price (%s => %s) (%s): %w", price, trimmedPrice, err.Error(), ErrSiteChanged)
 			errs = append(errs, err)
 		}
-		prices = append(prices, ResortPrice{Name: name, PricePerPoint: parsedPrice})
+		resortPrice := ResortPrice{Name: name, PricePerPoint: parsedPrice}
+		if resort, ok := catalog.ResortByName(name); ok {
+			resortPrice.Resort = resort.Code
+		}
+		prices = append(prices, resortPrice)
 	}
 
 	if len(errs) > 0 {
@@ -0,0 +1,201 @@
+package dvcscraper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-rod/rod/lib/proto"
+)
+
+const (
+	profileLockFile   = "dvcscraper.lock"
+	profileImportFile = "dvcscraper-imported"
+)
+
+// loginOrigins store the session outside of cookies: the member site and the
+// Disney ID sign in iframe, which is served from its own origin
+var loginOrigins = []string{
+	"https://disneyvacationclub.disney.go.com",
+	"https://cdn.registerdisney.go.com",
+	"https://registerdisney.go.com",
+}
+
+// profileLock holds a browser profile directory for this process until
+// released
+type profileLock struct {
+	dir  string
+	file *os.File
+}
+
+// lockProfile creates dir if needed and locks it, failing with
+// ErrProfileLocked when another process holds it
+func lockProfile(dir string) (*profileLock, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		err = fmt.Errorf("failed to create profile directory: %w", err)
+		return nil, err
+	}
+
+	file, err := lockFile(filepath.Join(dir, profileLockFile))
+	if err != nil {
+		return nil, err
+	}
+
+	return &profileLock{dir: dir, file: file}, nil
+}
+
+// release unlocks the profile. It's safe to call on a nil lock.
+func (l *profileLock) release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+
+	err := unlockFile(l.file)
+	l.file = nil
+	if err != nil {
+		err = fmt.Errorf("failed to unlock profile '%s': %w", l.dir, err)
+		return err
+	}
+
+	return nil
+}
+
+// importSession copies the cookies saved in the session store into a profile
+// the first time it's used, so switching to profiles doesn't need a new
+// login. Afterwards the profile carries the session on its own, except for
+// session cookies, which Chrome drops on exit; those are restored from the
+// session store, which Close keeps saving to.
+func (s *Scraper) importSession() error {
+	marker := filepath.Join(s.profile.dir, profileImportFile)
+	_, err := os.Stat(marker)
+	if err == nil {
+		return s.restoreSessionCookies()
+	} else if !os.IsNotExist(err) {
+		err = fmt.Errorf("failed to check for imported session: %w", err)
+		return err
+	}
+
+	_, err = s.sessions.Load()
+	if err == nil {
+		s.logger.Println("importing saved session into browser profile")
+	}
+
+	err = s.readCookies()
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(marker, nil, 0600)
+	if err != nil {
+		err = fmt.Errorf("failed to mark session as imported: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// restoreSessionCookies sets the session-only cookies saved in the session
+// store, leaving the persistent ones the profile already has alone
+func (s *Scraper) restoreSessionCookies() error {
+	raw, err := s.sessions.Load()
+	if errors.Is(err, ErrNoSession) {
+		return nil
+	} else if err != nil {
+		err = fmt.Errorf("failed to load session: %w", err)
+		return err
+	}
+
+	sessionOnly, err := sessionOnlyCookies(raw)
+	if err != nil {
+		return err
+	}
+	if len(sessionOnly) == 0 {
+		return nil
+	}
+
+	err = s.browser.SetCookies(proto.CookiesToParams(sessionOnly))
+	if err != nil {
+		err = fmt.Errorf("failed to set session cookies: %w", err)
+		return err
+	}
+	return nil
+}
+
+// sessionOnlyCookies returns the cookies in a saved session that the browser
+// drops on exit
+func sessionOnlyCookies(raw []byte) ([]*proto.NetworkCookie, error) {
+	cookies := []*proto.NetworkCookie{}
+	err := json.Unmarshal(raw, &cookies)
+	if err != nil {
+		return nil, &SessionCorruptError{Reason: "failed to unmarshal cookies", Err: err}
+	}
+
+	sessionOnly := []*proto.NetworkCookie{}
+	for _, cookie := range cookies {
+		if cookie.Session {
+			sessionOnly = append(sessionOnly, cookie)
+		}
+	}
+	return sessionOnly, nil
+}
+
+// clearSiteData deletes the storage of every origin the login uses from the
+// browser profile, since clearing cookies alone leaves local storage and
+// IndexedDB behind. Origins holding cookies are cleared as well, in case the
+// sign in moves somewhere loginOrigins doesn't know about.
+func (s *Scraper) clearSiteData() error {
+	origins := append([]string{}, loginOrigins...)
+
+	cookies, err := s.browser.GetCookies()
+	if err != nil {
+		err = fmt.Errorf("failed to get cookies: %w", err)
+		return err
+	}
+	for _, cookie := range cookies {
+		origin := "https://" + strings.TrimPrefix(cookie.Domain, ".")
+		if !hasOrigin(origins, origin) {
+			origins = append(origins, origin)
+		}
+	}
+
+	for _, origin := range origins {
+		err = proto.StorageClearDataForOrigin{
+			Origin:       origin,
+			StorageTypes: "all",
+		}.Call(s.browser)
+		if err != nil {
+			err = fmt.Errorf("failed to clear site data for '%s': %w", origin, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func hasOrigin(origins []string, origin string) bool {
+	for _, o := range origins {
+		if o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// profileError reports a profile that couldn't be locked as a failure to
+// start the browser
+func profileError(dir string, err error) error {
+	msg := fmt.Sprintf("failed to lock profile '%s': %s", dir, err.Error())
+	if errors.Is(err, ErrProfileLocked) {
+		msg = fmt.Sprintf("profile '%s' is in use by another process", dir)
+	}
+
+	return startingError{
+		msg:           msg,
+		failedToStart: true,
+		err:           err,
+	}
+}
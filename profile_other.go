@@ -0,0 +1,36 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package dvcscraper
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// lockFile creates path exclusively. A process that dies without closing
+// the Scraper leaves it behind; delete it by hand once nothing uses the
+// profile.
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return nil, ErrProfileLocked
+	} else if err != nil {
+		err = fmt.Errorf("failed to create lock file: %w", err)
+		return nil, err
+	}
+
+	_, _ = file.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+
+	return file, nil
+}
+
+func unlockFile(file *os.File) error {
+	err := file.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(file.Name())
+}
@@ -0,0 +1,177 @@
+package dvcscraper
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-rod/rod/lib/proto"
+)
+
+func TestLockProfile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "profiles", "alice")
+
+	lock, err := lockProfile(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = os.Stat(filepath.Join(dir, profileLockFile))
+	if err != nil {
+		t.Fatalf("lock file missing: %v", err)
+	}
+
+	_, err = lockProfile(dir)
+	if !errors.Is(err, ErrProfileLocked) {
+		t.Fatalf("second lock got %v, want %v", err, ErrProfileLocked)
+	}
+
+	other, err := lockProfile(filepath.Join(t.TempDir(), "bob"))
+	if err != nil {
+		t.Fatalf("another profile was blocked: %v", err)
+	}
+	_ = other.release()
+
+	err = lock.release()
+	if err != nil {
+		t.Fatalf("failed to release: %v", err)
+	}
+	err = lock.release()
+	if err != nil {
+		t.Fatalf("second release failed: %v", err)
+	}
+	var none *profileLock
+	err = none.release()
+	if err != nil {
+		t.Fatalf("releasing a nil lock failed: %v", err)
+	}
+
+	again, err := lockProfile(dir)
+	if err != nil {
+		t.Fatalf("failed to lock after release: %v", err)
+	}
+	_ = again.release()
+}
+
+func TestProfileError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantMsg string
+	}{
+		{name: "locked", err: ErrProfileLocked, wantMsg: "profile 'p' is in use by another process"},
+		{name: "other", err: errors.New("permission denied"), wantMsg: "failed to lock profile 'p': permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := profileError("p", tt.err)
+			if err.Error() != tt.wantMsg {
+				t.Fatalf("got %q, want %q", err.Error(), tt.wantMsg)
+			}
+			if !errors.Is(err, ErrBrowserLaunch) || !errors.Is(err, tt.err) {
+				t.Fatalf("%v doesn't match ErrBrowserLaunch and its cause", err)
+			}
+		})
+	}
+}
+
+// savedSession is a session as Close saves it: the browser's cookies as JSON
+func savedSession(t *testing.T, cookies ...*proto.NetworkCookie) []byte {
+	t.Helper()
+
+	raw, err := json.Marshal(cookies)
+	if err != nil {
+		t.Fatalf("failed to marshal cookies: %v", err)
+	}
+	return raw
+}
+
+func TestSessionOnlyCookies(t *testing.T) {
+	persistent := &proto.NetworkCookie{Name: "SWID", Value: "a", Domain: ".disney.go.com", Expires: 1900000000}
+	session := &proto.NetworkCookie{Name: "pep_oauth_token", Value: "b", Domain: ".disney.go.com", Expires: -1, Session: true}
+	other := &proto.NetworkCookie{Name: "ak_bmsc", Value: "c", Domain: ".go.com", Expires: -1, Session: true}
+
+	tests := []struct {
+		name    string
+		raw     []byte
+		want    []string
+		wantErr bool
+	}{
+		{name: "only persistent", raw: savedSession(t, persistent), want: []string{}},
+		{name: "mixed", raw: savedSession(t, persistent, session, other), want: []string{"pep_oauth_token=b", "ak_bmsc=c"}},
+		{name: "empty", raw: []byte("[]"), want: []string{}},
+		{name: "corrupt", raw: []byte("{"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookies, err := sessionOnlyCookies(tt.raw)
+			if tt.wantErr {
+				var corrupt *SessionCorruptError
+				if !errors.As(err, &corrupt) {
+					t.Fatalf("expected SessionCorruptError, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := []string{}
+			for _, cookie := range cookies {
+				got = append(got, cookie.Name+"="+cookie.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreSessionCookiesWithoutSessionOnly(t *testing.T) {
+	// with nothing to restore the browser, here nil, is never touched
+	persistent := &proto.NetworkCookie{Name: "SWID", Value: "a", Domain: ".disney.go.com", Expires: 1900000000}
+
+	tests := []struct {
+		name    string
+		saved   []byte
+		wantErr string
+	}{
+		{name: "no saved session"},
+		{name: "persistent cookies only", saved: savedSession(t, persistent)},
+		{name: "corrupt", saved: []byte("not json"), wantErr: "failed to unmarshal cookies"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemorySessionStore()
+			if tt.saved != nil {
+				_ = store.Save(tt.saved)
+			}
+			s := &Scraper{sessions: store, logger: log.New(io.Discard, "", 0)}
+
+			err := s.restoreSessionCookies()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestHasOrigin(t *testing.T) {
+	if !hasOrigin(loginOrigins, "https://registerdisney.go.com") {
+		t.Fatal("login origin not found")
+	}
+	if hasOrigin(loginOrigins, "https://disney.go.com") {
+		t.Fatal("found an origin that isn't listed")
+	}
+}
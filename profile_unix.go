@@ -0,0 +1,48 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package dvcscraper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// lockFile takes an exclusive flock on path. The kernel drops it if the
+// process dies, so a crash never leaves the profile locked.
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		err = fmt.Errorf("failed to open lock file: %w", err)
+		return nil, err
+	}
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		file.Close()
+		return nil, ErrProfileLocked
+	} else if err != nil {
+		file.Close()
+		err = fmt.Errorf("failed to lock file: %w", err)
+		return nil, err
+	}
+
+	// the PID is only informational, for whoever finds the profile busy
+	_ = file.Truncate(0)
+	_, _ = file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+
+	return file, nil
+}
+
+func unlockFile(file *os.File) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
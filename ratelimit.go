@@ -0,0 +1,173 @@
+package dvcscraper
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultRateLimitPause = time.Minute
+
+// EndpointClass groups requests that share a rate limit
+type EndpointClass string
+
+const (
+	// EndpointNavigation covers page loads in the browser
+	EndpointNavigation EndpointClass = "navigation"
+	// EndpointAPI covers booking API requests made from the page
+	EndpointAPI EndpointClass = "api"
+)
+
+// RateLimit is a token bucket: one request is allowed every Every, with up to
+// Burst requests allowed at once after a quiet period. An Every of zero
+// disables the limit.
+type RateLimit struct {
+	Every time.Duration
+	Burst int
+}
+
+// DefaultRateLimits returns the limits used for endpoint classes missing from
+// ScraperOptions.RateLimits. Each call returns a new map.
+func DefaultRateLimits() map[EndpointClass]RateLimit {
+	return map[EndpointClass]RateLimit{
+		EndpointNavigation: {Every: 3 * time.Second, Burst: 3},
+		EndpointAPI:        {Every: time.Second, Burst: 5},
+	}
+}
+
+// rateLimiter is shared by everything using a Scraper, so limits hold across
+// handles and goroutines
+type rateLimiter struct {
+	mu          sync.Mutex
+	buckets     map[EndpointClass]*bucket
+	pausedUntil time.Time
+}
+
+type bucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(limits map[EndpointClass]RateLimit) *rateLimiter {
+	r := &rateLimiter{buckets: map[EndpointClass]*bucket{}}
+
+	for class, limit := range DefaultRateLimits() {
+		if custom, ok := limits[class]; ok {
+			limit = custom
+		}
+		r.buckets[class] = newBucket(limit)
+	}
+	for class, limit := range limits {
+		if _, ok := r.buckets[class]; !ok {
+			r.buckets[class] = newBucket(limit)
+		}
+	}
+
+	return r
+}
+
+func newBucket(limit RateLimit) *bucket {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &bucket{limit: limit, tokens: float64(limit.Burst)}
+}
+
+// wait blocks until a request of the given class may be made
+func (r *rateLimiter) wait(ctx context.Context, class EndpointClass) error {
+	for {
+		delay := r.reserve(class)
+		if delay <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a token if one is available and otherwise returns how long to
+// wait before trying again
+func (r *rateLimiter) reserve(class EndpointClass) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(r.pausedUntil) {
+		return r.pausedUntil.Sub(now)
+	}
+
+	b, ok := r.buckets[class]
+	if !ok || b.limit.Every <= 0 {
+		return 0
+	}
+
+	if !b.last.IsZero() {
+		b.tokens += float64(now.Sub(b.last)) / float64(b.limit.Every)
+		if b.tokens > float64(b.limit.Burst) {
+			b.tokens = float64(b.limit.Burst)
+		}
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) * float64(b.limit.Every))
+}
+
+// pause stops every request class for d
+func (r *rateLimiter) pause(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	until := time.Now().Add(d)
+	if until.After(r.pausedUntil) {
+		r.pausedUntil = until
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date, falling back to a default pause
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+		return 0
+	}
+
+	return defaultRateLimitPause
+}
+
+func (s *Scraper) waitRateLimit(ctx context.Context, class EndpointClass) error {
+	if s.limiter == nil {
+		return nil
+	}
+	return s.limiter.wait(ctx, class)
+}
+
+// pauseRateLimit pauses all requests after the site said to slow down
+func (s *Scraper) pauseRateLimit(retryAfter time.Duration) {
+	if s.limiter == nil {
+		return
+	}
+	s.logger.Printf("rate limited; pausing all requests for %s", retryAfter)
+	s.limiter.pause(retryAfter)
+}
@@ -0,0 +1,151 @@
+package dvcscraper
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDefaultRateLimitsCopies(t *testing.T) {
+	limits := DefaultRateLimits()
+	limits[EndpointAPI] = RateLimit{}
+	delete(limits, EndpointNavigation)
+
+	again := DefaultRateLimits()
+	if again[EndpointAPI].Every == 0 || again[EndpointNavigation].Every == 0 {
+		t.Fatalf("changing one copy changed the defaults: %v", again)
+	}
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	custom := EndpointClass("custom")
+	r := newRateLimiter(map[EndpointClass]RateLimit{
+		EndpointAPI: {Every: time.Minute, Burst: 2},
+		custom:      {Every: time.Hour},
+	})
+
+	tests := []struct {
+		class EndpointClass
+		want  RateLimit
+	}{
+		{class: EndpointNavigation, want: DefaultRateLimits()[EndpointNavigation]},
+		{class: EndpointAPI, want: RateLimit{Every: time.Minute, Burst: 2}},
+		{class: custom, want: RateLimit{Every: time.Hour, Burst: 1}},
+	}
+
+	for _, tt := range tests {
+		b, ok := r.buckets[tt.class]
+		if !ok {
+			t.Fatalf("no bucket for %s", tt.class)
+		}
+		if b.limit != tt.want {
+			t.Fatalf("%s limit %+v, want %+v", tt.class, b.limit, tt.want)
+		}
+		if b.tokens != float64(tt.want.Burst) {
+			t.Fatalf("%s starts with %v tokens, want %d", tt.class, b.tokens, tt.want.Burst)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	r := newRateLimiter(map[EndpointClass]RateLimit{
+		EndpointAPI:        {Every: time.Hour, Burst: 2},
+		EndpointNavigation: {},
+	})
+
+	for i := 0; i < 2; i++ {
+		if delay := r.reserve(EndpointAPI); delay != 0 {
+			t.Fatalf("request %d within burst delayed %s", i+1, delay)
+		}
+	}
+	delay := r.reserve(EndpointAPI)
+	if delay < 59*time.Minute || delay > time.Hour {
+		t.Fatalf("request after burst delayed %s, want about an hour", delay)
+	}
+
+	for i := 0; i < 10; i++ {
+		if delay := r.reserve(EndpointNavigation); delay != 0 {
+			t.Fatalf("unlimited class delayed %s", delay)
+		}
+	}
+	if delay := r.reserve("unknown"); delay != 0 {
+		t.Fatalf("unknown class delayed %s", delay)
+	}
+}
+
+func TestReserveRefills(t *testing.T) {
+	r := newRateLimiter(map[EndpointClass]RateLimit{EndpointAPI: {Every: 10 * time.Millisecond, Burst: 1}})
+
+	if delay := r.reserve(EndpointAPI); delay != 0 {
+		t.Fatalf("first request delayed %s", delay)
+	}
+	if delay := r.reserve(EndpointAPI); delay <= 0 {
+		t.Fatal("second request not delayed")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if delay := r.reserve(EndpointAPI); delay != 0 {
+		t.Fatalf("request after refill delayed %s", delay)
+	}
+	if tokens := r.buckets[EndpointAPI].tokens; tokens > 1 {
+		t.Fatalf("bucket refilled past its burst: %v tokens", tokens)
+	}
+}
+
+func TestPause(t *testing.T) {
+	r := newRateLimiter(map[EndpointClass]RateLimit{EndpointNavigation: {}})
+
+	r.pause(time.Hour)
+	for _, class := range []EndpointClass{EndpointAPI, EndpointNavigation, "unknown"} {
+		delay := r.reserve(class)
+		if delay < 59*time.Minute || delay > time.Hour {
+			t.Fatalf("%s delayed %s while paused, want about an hour", class, delay)
+		}
+	}
+
+	r.pause(time.Minute)
+	if delay := r.reserve(EndpointAPI); delay < 59*time.Minute {
+		t.Fatalf("shorter pause cut the longer one to %s", delay)
+	}
+}
+
+func TestWaitCancelled(t *testing.T) {
+	r := newRateLimiter(nil)
+	r.pause(time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := r.wait(ctx, EndpointAPI)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{name: "seconds", value: "120", min: 2 * time.Minute, max: 2 * time.Minute},
+		{name: "spaces", value: " 5 ", min: 5 * time.Second, max: 5 * time.Second},
+		{name: "zero", value: "0", min: 0, max: 0},
+		{name: "negative", value: "-5", min: defaultRateLimitPause, max: defaultRateLimitPause},
+		{name: "empty", value: "", min: defaultRateLimitPause, max: defaultRateLimitPause},
+		{name: "garbage", value: "soon", min: defaultRateLimitPause, max: defaultRateLimitPause},
+		{name: "future date", value: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), min: 58 * time.Minute, max: time.Hour},
+		{name: "past date", value: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), min: 0, max: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseRetryAfter(tt.value)
+			if got < tt.min || got > tt.max {
+				t.Fatalf("parseRetryAfter(%q) = %s, want between %s and %s", tt.value, got, tt.min, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package dvcscraper
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = 30 * time.Second
+	defaultBackoffFactor  = 2
+)
+
+// RetryPolicy configures how failed scraping operations are retried. The zero
+// value makes a single attempt.
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry. Defaults to 1s.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between attempts. Defaults to 30s.
+	MaxBackoff time.Duration
+	// Multiplier grows the wait after each retry. Defaults to 2.
+	Multiplier float64
+	// Jitter randomizes each wait by up to this fraction of it, e.g. 0.2
+	Jitter float64
+	// Retryable decides which errors are retried. Defaults to IsRetryable.
+	Retryable func(error) bool
+}
+
+// IsRetryable reports whether err is worth retrying. Rejected logins, browser
+// launch failures, site changes, cancellation and client errors from the API
+// are not; everything else is assumed to be transient.
+func IsRetryable(err error) bool {
+	switch {
+	case errors.Is(err, context.Canceled),
+		errors.Is(err, ErrLoginRejected),
+		errors.Is(err, ErrBrowserLaunch),
+		errors.Is(err, ErrSiteChanged):
+		return false
+	case errors.Is(err, ErrRateLimited), errors.Is(err, ErrSessionExpired):
+		return true
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Status >= http.StatusInternalServerError
+	}
+
+	return true
+}
+
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// backoff returns the wait after the given failed attempt, starting at 1
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	initial := p.InitialBackoff
+	if initial == 0 {
+		initial = defaultInitialBackoff
+	}
+	max := p.MaxBackoff
+	if max == 0 {
+		max = defaultMaxBackoff
+	}
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = defaultBackoffFactor
+	}
+
+	wait := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if wait > float64(max) {
+		wait = float64(max)
+	}
+
+	if p.Jitter > 0 {
+		wait += wait * p.Jitter * (rand.Float64()*2 - 1)
+	}
+
+	return time.Duration(wait)
+}
+
+// withRetry runs fn under the Scraper's RetryPolicy. When an attempt fails
+// because the session expired, reauth runs before the next attempt. Retries
+// are counted against endpoint.
+func (s *Scraper) withRetry(ctx context.Context, endpoint, op string, reauth func(context.Context) error, fn func() error) error {
+	attempts := s.retry.attempts()
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			if attempt > 1 {
+				s.logger.Printf("%s succeeded on attempt %d/%d", op, attempt, attempts)
+			}
+			return nil
+		}
+
+		if attempt >= attempts || ctx.Err() != nil || !s.retry.retryable(err) {
+			if attempt > 1 {
+				s.logger.Printf("%s failed on attempt %d/%d, giving up: %s", op, attempt, attempts, err.Error())
+			}
+			return err
+		}
+
+		wait := s.retry.backoff(attempt)
+		s.logger.Printf("%s failed on attempt %d/%d, retrying in %s: %s", op, attempt, attempts, wait, err.Error())
+		s.metrics.Retry(endpoint)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		if errors.Is(err, ErrSessionExpired) && reauth != nil {
+			s.logger.Printf("%s re-authenticating before retry", op)
+			loginErr := reauth(ctx)
+			if loginErr != nil {
+				if isCertainlyLoginError(loginErr) {
+					return loginErr
+				}
+				s.logger.Println("Possible login error:", loginErr)
+			}
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package dvcscraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "unknown", err: errors.New("connection reset"), want: true},
+		{name: "deadline", err: context.DeadlineExceeded, want: true},
+		{name: "canceled", err: fmt.Errorf("failed: %w", context.Canceled), want: false},
+		{name: "login rejected", err: loginError{msg: "bad password", certainlyFailed: true}, want: false},
+		{name: "uncertain login", err: loginError{msg: "no dashboard"}, want: true},
+		{name: "browser launch", err: startingError{msg: "no chrome", failedToStart: true}, want: false},
+		{name: "site changed", err: fmt.Errorf("failed to parse: %w", ErrSiteChanged), want: false},
+		{name: "selector missing", err: &SelectorError{Selector: "#x", Err: errors.New("timeout")}, want: true},
+		{name: "server error", err: &APIError{Status: http.StatusBadGateway}, want: true},
+		{name: "client error", err: &APIError{Status: http.StatusBadRequest}, want: false},
+		{name: "not found", err: &APIError{Status: http.StatusNotFound}, want: false},
+		{name: "rate limited", err: &APIError{Status: http.StatusTooManyRequests}, want: true},
+		{name: "unauthorized", err: &APIError{Status: http.StatusUnauthorized}, want: true},
+		{name: "forbidden wrapped", err: fmt.Errorf("failed: %w", &APIError{Status: http.StatusForbidden}), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Fatalf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{name: "default first", policy: RetryPolicy{}, attempt: 1, want: time.Second},
+		{name: "default doubles", policy: RetryPolicy{}, attempt: 3, want: 4 * time.Second},
+		{name: "default cap", policy: RetryPolicy{}, attempt: 10, want: 30 * time.Second},
+		{name: "custom", policy: RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: 3}, attempt: 3, want: 900 * time.Millisecond},
+		{name: "custom cap", policy: RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}, attempt: 4, want: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.backoff(tt.attempt); got != tt.want {
+				t.Fatalf("backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, Jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		got := policy.backoff(1)
+		if got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			t.Fatalf("backoff %s outside 20%% of 1s", got)
+		}
+	}
+}
+
+func TestAttempts(t *testing.T) {
+	for _, tt := range []struct{ max, want int }{{0, 1}, {-2, 1}, {1, 1}, {4, 4}} {
+		if got := (RetryPolicy{MaxAttempts: tt.max}).attempts(); got != tt.want {
+			t.Fatalf("attempts with MaxAttempts %d = %d, want %d", tt.max, got, tt.want)
+		}
+	}
+}
+
+// retryMetrics counts retries
+type retryMetrics struct {
+	nopMetrics
+	retries map[string]int
+}
+
+func (m *retryMetrics) Retry(endpoint string) { m.retries[endpoint]++ }
+
+func retryScraper(policy RetryPolicy) (*Scraper, *retryMetrics) {
+	metrics := &retryMetrics{retries: map[string]int{}}
+	return &Scraper{
+		logger:  log.New(io.Discard, "", 0),
+		metrics: metrics,
+		retry:   policy,
+	}, metrics
+}
+
+func TestWithRetry(t *testing.T) {
+	transient := errors.New("connection reset")
+	expired := &APIError{Status: http.StatusUnauthorized}
+	fast := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	tests := []struct {
+		name        string
+		policy      RetryPolicy
+		results     []error
+		reauthErr   error
+		wantErr     error
+		wantCalls   int
+		wantReauths int
+		wantRetries int
+	}{
+		{name: "first try", policy: fast, results: []error{nil}, wantCalls: 1},
+		{name: "zero policy tries once", policy: RetryPolicy{}, results: []error{transient}, wantErr: transient, wantCalls: 1},
+		{name: "recovers", policy: fast, results: []error{transient, transient, nil}, wantCalls: 3, wantRetries: 2},
+		{name: "gives up", policy: fast, results: []error{transient, transient, transient}, wantErr: transient, wantCalls: 3, wantRetries: 2},
+		{name: "not retryable", policy: fast, results: []error{ErrSiteChanged}, wantErr: ErrSiteChanged, wantCalls: 1},
+		{
+			name:      "custom retryable",
+			policy:    RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Retryable: func(error) bool { return false }},
+			results:   []error{transient},
+			wantErr:   transient,
+			wantCalls: 1,
+		},
+		{name: "reauth between attempts", policy: fast, results: []error{expired, nil}, wantCalls: 2, wantReauths: 1, wantRetries: 1},
+		{name: "no reauth for other errors", policy: fast, results: []error{transient, nil}, wantCalls: 2, wantRetries: 1},
+		{
+			name:        "uncertain reauth failure still retries",
+			policy:      fast,
+			results:     []error{expired, nil},
+			reauthErr:   loginError{msg: "slow dashboard"},
+			wantCalls:   2,
+			wantReauths: 1,
+			wantRetries: 1,
+		},
+		{
+			name:        "rejected reauth stops",
+			policy:      fast,
+			results:     []error{expired, nil},
+			reauthErr:   loginError{msg: "bad password", certainlyFailed: true},
+			wantErr:     ErrLoginRejected,
+			wantCalls:   1,
+			wantReauths: 1,
+			wantRetries: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, metrics := retryScraper(tt.policy)
+
+			calls, reauths := 0, 0
+			reauth := func(context.Context) error {
+				reauths++
+				return tt.reauthErr
+			}
+			err := s.withRetry(context.Background(), endpointAvailability, "test", reauth, func() error {
+				result := tt.results[calls]
+				calls++
+				return result
+			})
+
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls || reauths != tt.wantReauths {
+				t.Fatalf("%d calls and %d reauths, want %d and %d", calls, reauths, tt.wantCalls, tt.wantReauths)
+			}
+			if metrics.retries[endpointAvailability] != tt.wantRetries {
+				t.Fatalf("%d retries counted, want %d", metrics.retries[endpointAvailability], tt.wantRetries)
+			}
+		})
+	}
+}
+
+func TestWithRetryStopsWhenCancelled(t *testing.T) {
+	s, _ := retryScraper(RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	transient := errors.New("connection reset")
+	calls := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- s.withRetry(ctx, endpointAvailability, "test", nil, func() error {
+			calls++
+			return transient
+		})
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != transient {
+			t.Fatalf("got %v, want the last attempt's error", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("withRetry kept waiting after cancellation")
+	}
+	if calls != 1 {
+		t.Fatalf("%d calls, want 1", calls)
+	}
+}
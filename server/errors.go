@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+)
+
+// badRequest is returned for invalid parameters
+type badRequest struct {
+	msg string
+}
+
+func (b badRequest) Error() string { return b.msg }
+
+// errQueueTimeout is returned when a request gave up waiting for the page
+type errQueueTimeout struct {
+	err error
+}
+
+func (e errQueueTimeout) Error() string { return "timed out waiting for the browser: " + e.err.Error() }
+func (e errQueueTimeout) Unwrap() error { return e.err }
+
+// errorResponse is the body of every failed request
+type errorResponse struct {
+	Error string `json:"error"`
+	// Kind is a stable name for the failure, e.g. "session_expired"
+	Kind string `json:"kind"`
+}
+
+// kindMessages are shown to clients in place of the underlying error, which
+// can carry internal details such as upstream response bodies
+var kindMessages = map[string]string{
+	"busy":            "timed out waiting for the browser",
+	"timeout":         "timed out",
+	"canceled":        "request canceled",
+	"otp_failed":      "the one-time passcode timed out or was rejected",
+	"login_rejected":  "the DVC site rejected the login",
+	"session_expired": "the DVC session expired",
+	"rate_limited":    "rate limited by the DVC site",
+	"site_changed":    "the DVC site changed or an expected element was missing",
+	"api_response":    "unexpected response from the DVC booking API",
+	"internal":        "internal error",
+}
+
+// classify maps an error to a response status and kind
+func classify(err error) (int, string) {
+	var bad badRequest
+	var queue errQueueTimeout
+	switch {
+	case errors.As(err, &bad):
+		return http.StatusBadRequest, "bad_request"
+	case errors.As(err, &queue):
+		return http.StatusServiceUnavailable, "busy"
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "timeout"
+	case errors.Is(err, context.Canceled):
+		// the client went away; the status is never seen
+		return http.StatusServiceUnavailable, "canceled"
+	case dvcscraper.IsOTPTimeout(err), dvcscraper.IsOTPRejected(err):
+		return http.StatusBadGateway, "otp_failed"
+	case errors.Is(err, dvcscraper.ErrLoginRejected):
+		return http.StatusBadGateway, "login_rejected"
+	case errors.Is(err, dvcscraper.ErrSessionExpired):
+		return http.StatusBadGateway, "session_expired"
+	case errors.Is(err, dvcscraper.ErrRateLimited):
+		return http.StatusTooManyRequests, "rate_limited"
+	case errors.Is(err, dvcscraper.ErrSiteChanged), errors.Is(err, dvcscraper.ErrSelectorNotFound):
+		return http.StatusBadGateway, "site_changed"
+	case errors.Is(err, dvcscraper.ErrAPIResponse):
+		return http.StatusBadGateway, "api_response"
+	}
+	return http.StatusInternalServerError, "internal"
+}
+
+// publicMessage is what the client is told about err. Only bad requests are
+// described in full.
+func publicMessage(kind string, err error) string {
+	if kind == "bad_request" {
+		return err.Error()
+	}
+	return kindMessages[kind]
+}
+
+func writeError(w http.ResponseWriter, status int, kind, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg, Kind: kind})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
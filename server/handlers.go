@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+	"github.com/lineleader/dvc-scraper/catalog"
+	"github.com/lineleader/dvc-scraper/output"
+)
+
+// maxStaySearches caps the room type months one stays request may search, so
+// a single request can't tie up the browser for every room in the catalog
+const maxStaySearches = 48
+
+// availability serves GET /availability?resort=BLT&room=4O&from=YYYY-MM-DD&to=YYYY-MM-DD
+func (s *Server) availability(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	opts := dvcscraper.AvailabilityRangeOptions{
+		Resort:   catalog.ResortCode(strings.ToUpper(query.Get("resort"))),
+		RoomType: catalog.RoomTypeCode(strings.ToUpper(query.Get("room"))),
+	}
+	if opts.Resort == "" || opts.RoomType == "" {
+		return badRequest{"resort and room are required"}
+	}
+
+	var err error
+	opts.Start, opts.End, err = dateParams(query)
+	if err != nil {
+		return err
+	}
+	err = opts.Validate()
+	if err != nil {
+		return badRequest{err.Error()}
+	}
+
+	var results dvcscraper.AvailabilityResults
+	err = s.withHandle(ctx, func(handle *dvcscraper.AvailabilityHandle) error {
+		var err error
+		results, err = handle.GetAvailabilityRangeContext(ctx, opts)
+		return err
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to get availability: %w", err)
+		return err
+	}
+
+	return writeDataset(w, output.Availability(results))
+}
+
+// stays serves GET /stays. resort and room take comma separated codes; resort
+// is required and with no room every catalog room type at the resorts is
+// searched. Room types the catalog doesn't list are searched when named in
+// room.
+// min_nights, max_nights, max_points, split and sort=points|checkin match the
+// stays command. Requests spanning more than maxStaySearches room type months
+// are refused.
+func (s *Server) stays(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	start, end, err := dateParams(query)
+	if err != nil {
+		return err
+	}
+
+	minNights, err := intParam(query, "min_nights", 1)
+	if err != nil {
+		return err
+	}
+	maxNights, err := intParam(query, "max_nights", 0)
+	if err != nil {
+		return err
+	}
+	maxPoints, err := intParam(query, "max_points", 0)
+	if err != nil {
+		return err
+	}
+	split, err := boolParam(query, "split")
+	if err != nil {
+		return err
+	}
+
+	order := dvcscraper.SortByPoints
+	switch query.Get("sort") {
+	case "", "points":
+	case "checkin":
+		order = dvcscraper.SortByCheckIn
+	default:
+		return badRequest{fmt.Sprintf("invalid sort '%s': want points or checkin", query.Get("sort"))}
+	}
+
+	sweep := dvcscraper.SweepOptions{Start: start, End: end, OnlyOpen: true}
+	for _, code := range listParam(query, "resort") {
+		sweep.Resorts = append(sweep.Resorts, catalog.ResortCode(code))
+	}
+	if len(sweep.Resorts) == 0 {
+		return badRequest{"resort is required"}
+	}
+	for _, code := range listParam(query, "room") {
+		sweep.RoomTypes = append(sweep.RoomTypes, catalog.RoomTypeCode(code))
+	}
+
+	roomTypes := len(sweep.MatchingRoomTypes())
+	if roomTypes == 0 {
+		return badRequest{"no catalog room types match resort and room; name room types in room to search others"}
+	}
+	if searches := roomTypes * monthsSpanned(start, end); searches > maxStaySearches {
+		return badRequest{fmt.Sprintf("too broad: %d room type months, limit %d; narrow room or the dates", searches, maxStaySearches)}
+	}
+
+	var all []dvcscraper.AvailabilityResults
+	err = s.withHandle(ctx, func(handle *dvcscraper.AvailabilityHandle) error {
+		var err error
+		all, err = handle.SweepAvailabilityContext(ctx, sweep)
+		return err
+	})
+	if partialSweep(err, roomTypes) {
+		// nights the failed room types would have added are missing, but
+		// the rest are served, even when none of them are open
+		s.logger.Println("stays sweep incomplete:", err)
+	} else if err != nil {
+		err = fmt.Errorf("failed to get availability: %w", err)
+		return err
+	}
+
+	stays := []dvcscraper.Stay{}
+	if split {
+		stays = dvcscraper.FindSplitStays(all, minNights, maxNights, maxPoints)
+	} else {
+		for _, results := range all {
+			stays = append(stays, dvcscraper.FindStays(results, minNights, maxNights, maxPoints)...)
+		}
+	}
+	dvcscraper.SortStays(stays, order)
+
+	return writeDataset(w, output.Stays(stays))
+}
+
+// partialSweep reports whether err is a sweep of roomTypes room types in
+// which only some failed
+func partialSweep(err error, roomTypes int) bool {
+	var failures dvcscraper.SweepErrors
+	return errors.As(err, &failures) && len(failures) < roomTypes
+}
+
+// dateParams reads from and to. from defaults to today and to to the end of
+// from's month.
+func dateParams(query url.Values) (time.Time, time.Time, error) {
+	start, end, err := dvcscraper.ParseDateRange(query.Get("from"), query.Get("to"), time.Now())
+	if err != nil {
+		return start, end, badRequest{err.Error()}
+	}
+	return start, end, nil
+}
+
+// monthsSpanned counts the calendar months from start to end inclusive
+func monthsSpanned(start, end time.Time) int {
+	return (end.Year()-start.Year())*12 + int(end.Month()-start.Month()) + 1
+}
+
+func intParam(query url.Values, name string, def int) (int, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return def, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return def, badRequest{fmt.Sprintf("invalid %s '%s'", name, raw)}
+	}
+	return value, nil
+}
+
+func boolParam(query url.Values, name string) (bool, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return false, nil
+	}
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, badRequest{fmt.Sprintf("invalid %s '%s'", name, raw)}
+	}
+	return value, nil
+}
+
+// listParam splits a comma separated parameter into upper case values
+func listParam(query url.Values, name string) []string {
+	values := []string{}
+	for _, v := range strings.Split(query.Get(name), ",") {
+		v = strings.ToUpper(strings.TrimSpace(v))
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+)
+
+func TestParamValidation(t *testing.T) {
+	s := newTestServer(t)
+
+	// each request is refused before it reaches the browser
+	tests := []struct {
+		name      string
+		target    string
+		wantError string
+	}{
+		{name: "availability without room", target: "/availability?resort=BLT", wantError: "resort and room are required"},
+		{name: "availability bad from", target: "/availability?resort=BLT&room=4O&from=May", wantError: "invalid from 'May': want YYYY-MM-DD"},
+		{name: "availability backwards", target: "/availability?resort=BLT&room=4O&from=2024-05-10&to=2024-05-01", wantError: "invalid to '2024-05-01': before from"},
+		{name: "stays without resort", target: "/stays?from=2024-05-01", wantError: "resort is required"},
+		{name: "stays bad to", target: "/stays?resort=BLT&to=soon", wantError: "invalid to 'soon': want YYYY-MM-DD"},
+		{name: "stays bad min nights", target: "/stays?resort=BLT&min_nights=two", wantError: "invalid min_nights 'two'"},
+		{name: "stays bad max points", target: "/stays?resort=BLT&max_points=lots", wantError: "invalid max_points 'lots'"},
+		{name: "stays bad split", target: "/stays?resort=BLT&split=maybe", wantError: "invalid split 'maybe'"},
+		{name: "stays bad sort", target: "/stays?resort=BLT&sort=price", wantError: "invalid sort 'price': want points or checkin"},
+		{name: "stays no catalog room types", target: "/stays?resort=VDH", wantError: "no catalog room types match resort and room; name room types in room to search others"},
+		{
+			name:      "stays too broad",
+			target:    "/stays?resort=BLT,VDH&room=4O,DS,1B&from=2024-01-01&to=2024-12-31",
+			wantError: "too broad: 72 room type months, limit 48; narrow room or the dates",
+		},
+		{name: "session bad probe", target: "/session?probe=sometimes", wantError: "invalid probe 'sometimes'"},
+		{name: "session bad window", target: "/session?expiring_within=soon", wantError: "invalid expiring_within 'soon'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(s, http.MethodGet, tt.target, nil)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status %d, want 400: %s", rec.Code, rec.Body)
+			}
+			body := decodeError(t, rec)
+			if body.Kind != "bad_request" || body.Error != tt.wantError {
+				t.Fatalf("got %s %q, want bad_request %q", body.Kind, body.Error, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestPartialSweep(t *testing.T) {
+	failure := dvcscraper.SweepFailure{Err: errors.New("timeout")}
+
+	tests := []struct {
+		name      string
+		err       error
+		roomTypes int
+		want      bool
+	}{
+		{name: "no error", err: nil, roomTypes: 2, want: false},
+		{name: "some failed", err: fmt.Errorf("sweep: %w", dvcscraper.SweepErrors{failure}), roomTypes: 2, want: true},
+		{name: "all failed", err: dvcscraper.SweepErrors{failure, failure}, roomTypes: 2, want: false},
+		{name: "other error", err: errors.New("browser closed"), roomTypes: 2, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := partialSweep(tt.err, tt.roomTypes); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMonthsSpanned(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		start, end time.Time
+		want       int
+	}{
+		{start: date(2024, 4, 1), end: date(2024, 4, 30), want: 1},
+		{start: date(2024, 4, 30), end: date(2024, 5, 1), want: 2},
+		{start: date(2024, 11, 15), end: date(2025, 2, 1), want: 4},
+	}
+
+	for _, tt := range tests {
+		if got := monthsSpanned(tt.start, tt.end); got != tt.want {
+			t.Fatalf("monthsSpanned(%s, %s) = %d, want %d", tt.start.Format("2006-01-02"), tt.end.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
+
+func TestListParam(t *testing.T) {
+	query := url.Values{"resort": {" blt,,vgf "}}
+
+	if got := listParam(query, "resort"); !reflect.DeepEqual(got, []string{"BLT", "VGF"}) {
+		t.Fatalf("got %q", got)
+	}
+	if got := listParam(query, "room"); len(got) != 0 {
+		t.Fatalf("got %q for a missing parameter", got)
+	}
+}
@@ -0,0 +1,243 @@
+// Package server exposes a long-lived Scraper as a JSON API over HTTP.
+//
+// Availability and stays requests share one AvailabilityHandle, so they're
+// served one at a time in arrival order; price and session requests run
+// alongside them on the Scraper's other pages. A request that waits longer
+// than its timeout for the handle gives up with 503.
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+	"github.com/lineleader/dvc-scraper/output"
+)
+
+const defaultTimeout = 2 * time.Minute
+
+// Options configure a Server
+type Options struct {
+	Scraper *dvcscraper.Scraper
+	// APIKeys are accepted as "Authorization: Bearer <key>" or an X-API-Key
+	// header. Empty disables authentication.
+	APIKeys []string
+	// Timeout bounds each request, including time spent waiting for a
+	// page. Defaults to 2 minutes.
+	Timeout time.Duration
+	Logger  *log.Logger
+}
+
+// Server serves the scraper's results as JSON. Create it with New.
+type Server struct {
+	scraper *dvcscraper.Scraper
+	apiKeys []string
+	timeout time.Duration
+	logger  *log.Logger
+	mux     *http.ServeMux
+
+	// turn holds the single token allowing use of handle
+	turn chan struct{}
+	// handle is reused between availability requests until one fails. Only
+	// touched while holding turn.
+	handle *dvcscraper.AvailabilityHandle
+}
+
+// New returns a Server over opts.Scraper
+func New(opts Options) (*Server, error) {
+	if opts.Scraper == nil {
+		return nil, errors.New("server needs a scraper")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+
+	s := &Server{
+		scraper: opts.Scraper,
+		apiKeys: opts.APIKeys,
+		timeout: opts.Timeout,
+		logger:  opts.Logger,
+		mux:     http.NewServeMux(),
+		turn:    make(chan struct{}, 1),
+	}
+	s.turn <- struct{}{}
+
+	s.mux.HandleFunc("/healthz", s.healthz)
+	s.mux.Handle("/session", s.api(s.session))
+	s.mux.Handle("/prices", s.api(s.prices))
+	s.mux.Handle("/availability", s.api(s.availability))
+	s.mux.Handle("/stays", s.api(s.stays))
+
+	return s, nil
+}
+
+// ServeHTTP routes r
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Handle registers an extra handler behind the server's mux, e.g. for metrics
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// apiFunc handles an authenticated GET request with a bounded context
+type apiFunc func(ctx context.Context, w http.ResponseWriter, r *http.Request) error
+
+// api wraps fn with method and key checks, the request timeout and error
+// responses
+func (s *Server) api(fn apiFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "only GET is supported")
+			return
+		}
+		if !s.authorized(r) {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid API key")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+		defer cancel()
+
+		err := fn(ctx, w, r)
+		if err != nil {
+			status, kind := classify(err)
+			if status != http.StatusBadRequest {
+				s.logger.Printf("%s %s failed: %s", r.Method, r.URL.Path, err)
+			}
+			writeError(w, status, kind, publicMessage(kind, err))
+		}
+	})
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if len(s.apiKeys) == 0 {
+		return true
+	}
+
+	key := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		key = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if key == "" {
+		return false
+	}
+
+	ok := false
+	for _, want := range s.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(want)) == 1 {
+			ok = true
+		}
+	}
+	return ok
+}
+
+// withHandle runs fn with the availability handle once it's this request's
+// turn. A failure may leave the handle's page anywhere, so the handle is
+// closed and the next request opens a new one.
+func (s *Server) withHandle(ctx context.Context, fn func(*dvcscraper.AvailabilityHandle) error) error {
+	select {
+	case <-s.turn:
+	case <-ctx.Done():
+		return errQueueTimeout{ctx.Err()}
+	}
+	defer func() { s.turn <- struct{}{} }()
+
+	if s.handle == nil {
+		handle, err := s.scraper.NewAvailabilityHandleContext(ctx)
+		if err != nil {
+			err = fmt.Errorf("failed to get availability handle: %w", err)
+			return err
+		}
+		s.handle = handle
+	}
+
+	err := fn(s.handle)
+	if err != nil {
+		s.handle.Close()
+		s.handle = nil
+	}
+	return err
+}
+
+// Close releases the availability handle's page. The Scraper is left open.
+func (s *Server) Close() error {
+	<-s.turn
+	defer func() { s.turn <- struct{}{} }()
+
+	if s.handle == nil {
+		return nil
+	}
+	err := s.handle.Close()
+	s.handle = nil
+	return err
+}
+
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) session(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	opts := dvcscraper.SessionStatusOptions{}
+
+	query := r.URL.Query()
+	probe, err := boolParam(query, "probe")
+	if err != nil {
+		return err
+	}
+	opts.Probe = probe
+	if raw := query.Get("expiring_within"); raw != "" {
+		opts.ExpiringWithin, err = time.ParseDuration(raw)
+		if err != nil {
+			return badRequest{fmt.Sprintf("invalid expiring_within '%s'", raw)}
+		}
+	}
+
+	status, err := s.scraper.SessionStatusContext(ctx, opts)
+	if err != nil {
+		err = fmt.Errorf("failed to check session: %w", err)
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		SchemaVersion int `json:"schemaVersion"`
+		dvcscraper.SessionStatus
+	}{output.SchemaVersion, status})
+	return nil
+}
+
+func (s *Server) prices(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	prices, err := s.scraper.GetPurchasePricesContext(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to get purchase prices: %w", err)
+		return err
+	}
+
+	return writeDataset(w, output.Prices(prices))
+}
+
+// writeDataset encodes d before writing anything, so an encoding failure can
+// still be reported with an error status
+func writeDataset(w http.ResponseWriter, d output.Dataset) error {
+	buf := bytes.Buffer{}
+	err := output.Write(&buf, output.JSON, d)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = buf.WriteTo(w)
+	return nil
+}
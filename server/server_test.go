@@ -0,0 +1,210 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dvcscraper "github.com/lineleader/dvc-scraper"
+	"github.com/lineleader/dvc-scraper/output"
+)
+
+// newTestServer returns a Server over a Scraper that was never started, so
+// only requests failing before they reach the browser can be served
+func newTestServer(t *testing.T, keys ...string) *Server {
+	t.Helper()
+
+	s, err := New(Options{
+		Scraper: &dvcscraper.Scraper{},
+		APIKeys: keys,
+		Logger:  log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
+}
+
+// get serves a request for target with the given headers
+func get(handler http.Handler, method, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeError reads an error response body
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) errorResponse {
+	t.Helper()
+
+	body := errorResponse{}
+	err := json.NewDecoder(rec.Body).Decode(&body)
+	if err != nil {
+		t.Fatalf("failed to decode error response: %v", err)
+	}
+	return body
+}
+
+func TestNewNeedsScraper(t *testing.T) {
+	_, err := New(Options{})
+	if err == nil {
+		t.Fatal("expected error without a scraper")
+	}
+}
+
+func TestAuth(t *testing.T) {
+	ok := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		return nil
+	}
+
+	tests := []struct {
+		name    string
+		keys    []string
+		method  string
+		headers map[string]string
+		want    int
+	}{
+		{name: "no keys configured", method: http.MethodGet, want: http.StatusOK},
+		{name: "bearer", keys: []string{"k1", "k2"}, method: http.MethodGet, headers: map[string]string{"Authorization": "Bearer k2"}, want: http.StatusOK},
+		{name: "api key header", keys: []string{"k1"}, method: http.MethodGet, headers: map[string]string{"X-API-Key": "k1"}, want: http.StatusOK},
+		{name: "missing", keys: []string{"k1"}, method: http.MethodGet, want: http.StatusUnauthorized},
+		{name: "wrong", keys: []string{"k1"}, method: http.MethodGet, headers: map[string]string{"X-API-Key": "k2"}, want: http.StatusUnauthorized},
+		{name: "prefix of a key", keys: []string{"k1"}, method: http.MethodGet, headers: map[string]string{"X-API-Key": "k"}, want: http.StatusUnauthorized},
+		{name: "not bearer", keys: []string{"k1"}, method: http.MethodGet, headers: map[string]string{"Authorization": "Basic k1"}, want: http.StatusUnauthorized},
+		{
+			name:    "bearer wins over api key header",
+			keys:    []string{"k1"},
+			method:  http.MethodGet,
+			headers: map[string]string{"Authorization": "Bearer wrong", "X-API-Key": "k1"},
+			want:    http.StatusUnauthorized,
+		},
+		{name: "post", method: http.MethodPost, want: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.keys...)
+			rec := get(s.api(ok), tt.method, "/prices", tt.headers)
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestRoutesRequireAuth(t *testing.T) {
+	s := newTestServer(t, "k1")
+
+	for _, path := range []string{"/session", "/prices", "/availability", "/stays"} {
+		rec := get(s, http.MethodGet, path, nil)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("%s without a key gave %d, want 401", path, rec.Code)
+		}
+		if body := decodeError(t, rec); body.Kind != "unauthorized" {
+			t.Fatalf("%s kind %q", path, body.Kind)
+		}
+	}
+
+	rec := get(s, http.MethodGet, "/healthz", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("healthz gave %d, want 200", rec.Code)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	upstream := &dvcscraper.APIError{URL: "https://internal.example/api", Status: http.StatusInternalServerError, Body: "stack trace"}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantKind   string
+		wantError  string
+	}{
+		{name: "bad request", err: badRequest{"invalid sort 'price'"}, wantStatus: http.StatusBadRequest, wantKind: "bad_request", wantError: "invalid sort 'price'"},
+		{name: "queue timeout", err: errQueueTimeout{context.DeadlineExceeded}, wantStatus: http.StatusServiceUnavailable, wantKind: "busy"},
+		{name: "deadline", err: fmt.Errorf("failed to get availability: %w", context.DeadlineExceeded), wantStatus: http.StatusGatewayTimeout, wantKind: "timeout"},
+		{name: "canceled", err: context.Canceled, wantStatus: http.StatusServiceUnavailable, wantKind: "canceled"},
+		{name: "login rejected", err: fmt.Errorf("failed to log in: %w", dvcscraper.ErrLoginRejected), wantStatus: http.StatusBadGateway, wantKind: "login_rejected"},
+		{name: "session expired", err: &dvcscraper.APIError{Status: http.StatusForbidden, Body: "token abc"}, wantStatus: http.StatusBadGateway, wantKind: "session_expired"},
+		{name: "rate limited", err: &dvcscraper.APIError{Status: http.StatusTooManyRequests}, wantStatus: http.StatusTooManyRequests, wantKind: "rate_limited"},
+		{name: "site changed", err: &dvcscraper.SelectorError{Selector: "#price", Err: errors.New("timeout")}, wantStatus: http.StatusBadGateway, wantKind: "site_changed"},
+		{name: "upstream body", err: fmt.Errorf("failed to get availability: %w", upstream), wantStatus: http.StatusBadGateway, wantKind: "api_response"},
+		{name: "internal", err: errors.New("open /var/lib/dvc/session.json: permission denied"), wantStatus: http.StatusInternalServerError, wantKind: "internal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			fail := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				return tt.err
+			}
+
+			rec := get(s.api(fail), http.MethodGet, "/availability", nil)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			body := decodeError(t, rec)
+			if body.Kind != tt.wantKind {
+				t.Fatalf("kind %q, want %q", body.Kind, tt.wantKind)
+			}
+
+			want := tt.wantError
+			if want == "" {
+				want = kindMessages[tt.wantKind]
+			}
+			if body.Error != want {
+				t.Fatalf("error %q, want %q", body.Error, want)
+			}
+		})
+	}
+}
+
+func TestWriteDataset(t *testing.T) {
+	s := newTestServer(t)
+	respond := func(prices []dvcscraper.ResortPrice) apiFunc {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return writeDataset(w, output.Prices(prices))
+		}
+	}
+
+	rec := get(s.api(respond([]dvcscraper.ResortPrice{{Name: "Riviera", Resort: "RIV", PricePerPoint: 220}})), http.MethodGet, "/prices", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	doc := struct {
+		SchemaVersion int    `json:"schemaVersion"`
+		Kind          string `json:"kind"`
+	}{}
+	err := json.NewDecoder(rec.Body).Decode(&doc)
+	if err != nil || doc.SchemaVersion != output.SchemaVersion || doc.Kind == "" {
+		t.Fatalf("document %+v, %v", doc, err)
+	}
+
+	// NaN can't be encoded as JSON, so nothing is written before the error
+	rec = get(s.api(respond([]dvcscraper.ResortPrice{{Name: "Riviera", PricePerPoint: math.NaN()}})), http.MethodGet, "/prices", nil)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, want 500", rec.Code)
+	}
+	dec := json.NewDecoder(rec.Body)
+	body := errorResponse{}
+	err = dec.Decode(&body)
+	if err != nil || body.Kind != "internal" {
+		t.Fatalf("error response %+v, %v", body, err)
+	}
+	if dec.More() || strings.Contains(rec.Body.String(), "Riviera") {
+		t.Fatalf("partial dataset written before the error: %s", rec.Body)
+	}
+}
@@ -0,0 +1,156 @@
+package dvcscraper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrNoSession is returned by a SessionStore's Load when there is no
+// previously saved session
+var ErrNoSession = errors.New("no saved session")
+
+// SessionStore persists the browser session between scraper runs.
+//
+// The stored bytes are the JSON produced by `GetCookies` and are handed back
+// to `SetCookies` when a Scraper starts.
+type SessionStore interface {
+	// Load returns the saved session or ErrNoSession if there is none
+	Load() ([]byte, error)
+	// Save replaces the saved session
+	Save([]byte) error
+	// Delete removes the saved session; deleting a missing session is not an error
+	Delete() error
+}
+
+// FileSessionStore keeps the session in a single file
+type FileSessionStore struct {
+	Path string
+}
+
+// NewFileSessionStore returns a SessionStore backed by the file at path
+func NewFileSessionStore(path string) *FileSessionStore {
+	return &FileSessionStore{Path: path}
+}
+
+// Load reads the session file
+func (f *FileSessionStore) Load() ([]byte, error) {
+	raw, err := os.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return nil, ErrNoSession
+	} else if err != nil {
+		err = fmt.Errorf("failed to read session file: %w", err)
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// Save writes the session file, readable only by the current user
+func (f *FileSessionStore) Save(raw []byte) error {
+	dir := filepath.Dir(f.Path)
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		err = fmt.Errorf("failed to create session directory: %w", err)
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(f.Path)+".*")
+	if err != nil {
+		err = fmt.Errorf("failed to create temporary session file: %w", err)
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(raw)
+	if err != nil {
+		tmp.Close()
+		err = fmt.Errorf("failed to write session file: %w", err)
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		err = fmt.Errorf("failed to close session file: %w", err)
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), f.Path)
+	if err != nil {
+		err = fmt.Errorf("failed to replace session file: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes the session file
+func (f *FileSessionStore) Delete() error {
+	err := os.Remove(f.Path)
+	if err != nil && !os.IsNotExist(err) {
+		err = fmt.Errorf("failed to remove session file: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// MemorySessionStore keeps the session in memory. It is useful for tests and
+// for sharing one session between Scrapers in the same process.
+type MemorySessionStore struct {
+	mu  sync.Mutex
+	raw []byte
+}
+
+// NewMemorySessionStore returns an empty in-memory SessionStore
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{}
+}
+
+// Load returns a copy of the saved session
+func (m *MemorySessionStore) Load() ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.raw == nil {
+		return nil, ErrNoSession
+	}
+
+	return append([]byte{}, m.raw...), nil
+}
+
+// Save keeps a copy of raw
+func (m *MemorySessionStore) Save(raw []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.raw = append([]byte{}, raw...)
+	return nil
+}
+
+// Delete forgets the saved session
+func (m *MemorySessionStore) Delete() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.raw = nil
+	return nil
+}
+
+// NewDirSessionStore returns a SessionStore that keeps one session file per
+// account inside dir. Several workers can share dir without their sessions
+// mixing as long as each uses its own account name.
+func NewDirSessionStore(dir, account string) (*FileSessionStore, error) {
+	if account == "" {
+		return nil, errors.New("account name is required for a directory session store")
+	}
+
+	name := filepath.Base(filepath.Clean(account))
+	if name != account || name == "." || name == ".." {
+		return nil, fmt.Errorf("invalid account name for session store: '%s'", account)
+	}
+
+	return NewFileSessionStore(filepath.Join(dir, name+".json")), nil
+}
@@ -0,0 +1,273 @@
+package dvcscraper
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	sessionMagic          = "DVCS"
+	sessionFormatVersion  = 1
+	sessionKeyIDSize      = 4
+	sessionHeaderSize     = len(sessionMagic) + 1 + sessionKeyIDSize
+	defaultSessionKeySize = 32
+)
+
+var (
+	// ErrPlaintextSession is returned when a saved session is not encrypted
+	// but encryption is required
+	ErrPlaintextSession = errors.New("saved session is not encrypted")
+	// ErrUnknownSessionKey is returned when a saved session was encrypted with
+	// a key that is not among the configured keys
+	ErrUnknownSessionKey = errors.New("saved session was encrypted with an unknown key")
+)
+
+// SessionCorruptError is returned when a saved session can't be decoded
+type SessionCorruptError struct {
+	Reason string
+	Err    error
+}
+
+func (e *SessionCorruptError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("saved session is corrupt: %s: %s", e.Reason, e.Err.Error())
+	}
+	return fmt.Sprintf("saved session is corrupt: %s", e.Reason)
+}
+
+func (e *SessionCorruptError) Unwrap() error { return e.Err }
+
+// SessionKey is an AES key used to encrypt saved sessions. It must be 16, 24
+// or 32 bytes long.
+type SessionKey []byte
+
+// GenerateSessionKey returns a new random 32 byte SessionKey
+func GenerateSessionKey() (SessionKey, error) {
+	key := make(SessionKey, defaultSessionKeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		err = fmt.Errorf("failed to generate session key: %w", err)
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// String returns the key base64 encoded, the format accepted by ParseSessionKeys
+func (k SessionKey) String() string {
+	return base64.StdEncoding.EncodeToString(k)
+}
+
+func (k SessionKey) id() []byte {
+	sum := sha256.Sum256(k)
+	return sum[:sessionKeyIDSize]
+}
+
+// ParseSessionKeys parses a comma or newline separated list of base64 encoded
+// keys. The first key encrypts new sessions; the rest are only used to decrypt
+// sessions saved before a key rotation.
+func ParseSessionKeys(raw string) ([]SessionKey, error) {
+	fields := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	})
+
+	keys := []SessionKey{}
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		key, err := base64.StdEncoding.DecodeString(field)
+		if err != nil {
+			err = fmt.Errorf("failed to decode session key: %w", err)
+			return nil, err
+		}
+
+		err = validSessionKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no session keys found")
+	}
+
+	return keys, nil
+}
+
+// SessionKeysFromEnv reads session keys from the named environment variable.
+// See ParseSessionKeys for the format.
+func SessionKeysFromEnv(name string) ([]SessionKey, error) {
+	raw, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("session key variable '%s' is not set", name)
+	}
+
+	return ParseSessionKeys(raw)
+}
+
+// SessionKeysFromFile reads session keys from a file, one per line. See
+// ParseSessionKeys for the format.
+func SessionKeysFromFile(path string) ([]SessionKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		err = fmt.Errorf("failed to read session key file: %w", err)
+		return nil, err
+	}
+
+	return ParseSessionKeys(string(raw))
+}
+
+func validSessionKey(key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	}
+	return fmt.Errorf("invalid session key length %d; must be 16, 24 or 32 bytes", len(key))
+}
+
+// EncryptedSessionStore wraps another SessionStore and encrypts sessions with
+// AES-GCM before they are saved.
+//
+// Saved sessions start with a versioned header naming the key that encrypted
+// them, so keys can be rotated by putting the new key first and keeping the
+// old ones around until every session has been saved again.
+type EncryptedSessionStore struct {
+	Store SessionStore
+	Keys  []SessionKey
+
+	// RequireEncryption refuses to load plaintext sessions. Without it a
+	// plaintext session is loaded as-is and encrypted the next time it's saved.
+	RequireEncryption bool
+}
+
+// NewEncryptedSessionStore returns an EncryptedSessionStore around store
+func NewEncryptedSessionStore(store SessionStore, keys ...SessionKey) (*EncryptedSessionStore, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one session key is required")
+	}
+
+	for _, key := range keys {
+		err := validSessionKey(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &EncryptedSessionStore{Store: store, Keys: keys}, nil
+}
+
+// Load reads and decrypts the saved session
+func (e *EncryptedSessionStore) Load() ([]byte, error) {
+	raw, err := e.Store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(raw, []byte(sessionMagic)) {
+		if e.RequireEncryption {
+			return nil, ErrPlaintextSession
+		}
+		return raw, nil
+	}
+
+	if len(raw) < sessionHeaderSize {
+		return nil, &SessionCorruptError{Reason: "truncated header"}
+	}
+
+	version := raw[len(sessionMagic)]
+	if version != sessionFormatVersion {
+		return nil, &SessionCorruptError{Reason: fmt.Sprintf("unsupported format version %d", version)}
+	}
+
+	header := raw[:sessionHeaderSize]
+	keyID := header[len(sessionMagic)+1:]
+	for _, key := range e.Keys {
+		if !bytes.Equal(key.id(), keyID) {
+			continue
+		}
+
+		gcm, err := newSessionGCM(key)
+		if err != nil {
+			return nil, err
+		}
+
+		body := raw[sessionHeaderSize:]
+		if len(body) < gcm.NonceSize() {
+			return nil, &SessionCorruptError{Reason: "truncated nonce"}
+		}
+
+		nonce, ciphertext := body[:gcm.NonceSize()], body[gcm.NonceSize():]
+		plain, err := gcm.Open(nil, nonce, ciphertext, header)
+		if err != nil {
+			return nil, &SessionCorruptError{Reason: "failed to decrypt", Err: err}
+		}
+
+		return plain, nil
+	}
+
+	return nil, ErrUnknownSessionKey
+}
+
+// Save encrypts raw with the first key and saves it
+func (e *EncryptedSessionStore) Save(raw []byte) error {
+	if len(e.Keys) == 0 {
+		return errors.New("no session key to encrypt with")
+	}
+	key := e.Keys[0]
+
+	gcm, err := newSessionGCM(key)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 0, sessionHeaderSize)
+	header = append(header, sessionMagic...)
+	header = append(header, sessionFormatVersion)
+	header = append(header, key.id()...)
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		err = fmt.Errorf("failed to generate nonce: %w", err)
+		return err
+	}
+
+	out := append(header, nonce...)
+	out = gcm.Seal(out, nonce, raw, header)
+
+	return e.Store.Save(out)
+}
+
+// Delete removes the saved session
+func (e *EncryptedSessionStore) Delete() error {
+	return e.Store.Delete()
+}
+
+func newSessionGCM(key SessionKey) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		err = fmt.Errorf("failed to create session cipher: %w", err)
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		err = fmt.Errorf("failed to create session GCM: %w", err)
+		return nil, err
+	}
+
+	return gcm, nil
+}
@@ -0,0 +1,194 @@
+package dvcscraper
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testSessionKey(size int, fill byte) SessionKey {
+	return SessionKey(bytes.Repeat([]byte{fill}, size))
+}
+
+func TestParseSessionKeys(t *testing.T) {
+	k16 := testSessionKey(16, 1).String()
+	k24 := testSessionKey(24, 2).String()
+	k32 := testSessionKey(32, 3).String()
+	short := base64.StdEncoding.EncodeToString([]byte("too short"))
+
+	tests := []struct {
+		name    string
+		raw     string
+		want    int
+		wantErr bool
+	}{
+		{name: "single key", raw: k32, want: 1},
+		{name: "comma separated", raw: k32 + "," + k16, want: 2},
+		{name: "newline separated", raw: k32 + "\n" + k24 + "\r\n" + k16 + "\n", want: 3},
+		{name: "surrounding whitespace", raw: "  " + k32 + " , " + k16 + "  ", want: 2},
+		{name: "empty fields skipped", raw: ",," + k32 + ",,", want: 1},
+		{name: "empty", raw: "", wantErr: true},
+		{name: "only separators", raw: " , \n ", wantErr: true},
+		{name: "not base64", raw: "not-base64!", wantErr: true},
+		{name: "bad length", raw: short, wantErr: true},
+		{name: "one bad key", raw: k32 + "," + short, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ParseSessionKeys(tt.raw)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %d keys", len(keys))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(keys) != tt.want {
+				t.Fatalf("got %d keys, want %d", len(keys), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSessionKeysOrder(t *testing.T) {
+	first, second := testSessionKey(32, 1), testSessionKey(32, 2)
+
+	keys, err := ParseSessionKeys(first.String() + "," + second.String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(keys[0], first) || !bytes.Equal(keys[1], second) {
+		t.Fatal("keys not returned in the order given")
+	}
+}
+
+func TestEncryptedSessionStoreLoad(t *testing.T) {
+	current := testSessionKey(32, 1)
+	previous := testSessionKey(16, 2)
+	unknown := testSessionKey(32, 3)
+	session := []byte(`[{"name":"a","value":"b"}]`)
+
+	sealWith := func(key SessionKey) []byte {
+		mem := NewMemorySessionStore()
+		store, err := NewEncryptedSessionStore(mem, key)
+		if err != nil {
+			t.Fatalf("failed to create store: %v", err)
+		}
+		err = store.Save(session)
+		if err != nil {
+			t.Fatalf("failed to save: %v", err)
+		}
+		raw, _ := mem.Load()
+		return raw
+	}
+
+	sealed := sealWith(current)
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 0xff
+	badVersion := append([]byte{}, sealed...)
+	badVersion[len(sessionMagic)] = sessionFormatVersion + 1
+
+	tests := []struct {
+		name    string
+		saved   []byte
+		keys    []SessionKey
+		require bool
+		want    []byte
+		wantErr error
+		corrupt bool
+	}{
+		{name: "current key", saved: sealed, keys: []SessionKey{current}, want: session},
+		{name: "rotated key", saved: sealWith(previous), keys: []SessionKey{current, previous}, want: session},
+		{name: "unknown key", saved: sealWith(unknown), keys: []SessionKey{current, previous}, wantErr: ErrUnknownSessionKey},
+		{name: "plaintext allowed", saved: session, keys: []SessionKey{current}, want: session},
+		{name: "plaintext required", saved: session, keys: []SessionKey{current}, require: true, wantErr: ErrPlaintextSession},
+		{name: "truncated header", saved: []byte(sessionMagic + "\x01"), keys: []SessionKey{current}, corrupt: true},
+		{name: "truncated nonce", saved: sealed[:sessionHeaderSize+2], keys: []SessionKey{current}, corrupt: true},
+		{name: "unsupported version", saved: badVersion, keys: []SessionKey{current}, corrupt: true},
+		{name: "tampered", saved: tampered, keys: []SessionKey{current}, corrupt: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := NewMemorySessionStore()
+			_ = mem.Save(tt.saved)
+
+			store, err := NewEncryptedSessionStore(mem, tt.keys...)
+			if err != nil {
+				t.Fatalf("failed to create store: %v", err)
+			}
+			store.RequireEncryption = tt.require
+
+			got, err := store.Load()
+			switch {
+			case tt.corrupt:
+				var corrupt *SessionCorruptError
+				if !errors.As(err, &corrupt) {
+					t.Fatalf("expected SessionCorruptError, got %v", err)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case !bytes.Equal(got, tt.want):
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptedSessionStoreSaveHeader(t *testing.T) {
+	key := testSessionKey(32, 1)
+	mem := NewMemorySessionStore()
+	store, err := NewEncryptedSessionStore(mem, key, testSessionKey(32, 2))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	err = store.Save([]byte("session"))
+	if err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	raw, _ := mem.Load()
+	if !strings.HasPrefix(string(raw), sessionMagic) {
+		t.Fatalf("missing magic: %q", raw[:len(sessionMagic)])
+	}
+	if raw[len(sessionMagic)] != sessionFormatVersion {
+		t.Fatalf("got version %d, want %d", raw[len(sessionMagic)], sessionFormatVersion)
+	}
+	if !bytes.Equal(raw[len(sessionMagic)+1:sessionHeaderSize], key.id()) {
+		t.Fatal("header doesn't name the first key")
+	}
+	if bytes.Contains(raw, []byte("session")) {
+		t.Fatal("session saved in plaintext")
+	}
+}
+
+func TestNewEncryptedSessionStoreKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []SessionKey
+		wantErr bool
+	}{
+		{name: "no keys", wantErr: true},
+		{name: "bad length", keys: []SessionKey{testSessionKey(10, 1)}, wantErr: true},
+		{name: "valid", keys: []SessionKey{testSessionKey(16, 1), testSessionKey(32, 2)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewEncryptedSessionStore(NewMemorySessionStore(), tt.keys...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
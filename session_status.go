@@ -0,0 +1,202 @@
+package dvcscraper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+const (
+	sessionProbeURL     = "https://disneyvacationclub.disney.go.com/home/"
+	sessionProbeTimeout = 30 * time.Second
+	sessionCookieDomain = "disney.go.com"
+
+	defaultSessionExpiringWithin = 30 * time.Minute
+)
+
+// authCookieNames are the cookies expected on a signed in session. They're
+// inferred from what the site sets after a login, not from anything it
+// documents, so checks based on them are a heuristic.
+var authCookieNames = []string{"SWID", "pep_oauth_token"}
+
+// SessionState summarizes whether the current session can be used
+type SessionState string
+
+const (
+	// SessionUnknown means the cookies don't say when the session ends
+	SessionUnknown SessionState = "unknown"
+	// SessionValid means the session should work for a while yet
+	SessionValid SessionState = "valid"
+	// SessionExpiringSoon means the session works but will expire shortly
+	SessionExpiringSoon SessionState = "expiring_soon"
+	// SessionExpired means a login is needed
+	SessionExpired SessionState = "expired"
+)
+
+// SessionStatus describes the Scraper's current session
+type SessionStatus struct {
+	State SessionState `json:"state"`
+	// ExpiresAt is the earliest expiry of the auth cookies, zero if unknown
+	ExpiresAt time.Time `json:"expiresAt"`
+	// MissingCookies lists auth cookies absent from the browser
+	MissingCookies []string `json:"missingCookies,omitempty"`
+	// Probed is true when the state was confirmed against the site
+	Probed bool `json:"probed"`
+}
+
+// SessionStatusOptions configure a session status check
+type SessionStatusOptions struct {
+	// ExpiringWithin is how close to expiry a session counts as expiring
+	// soon. Defaults to 30 minutes.
+	ExpiringWithin time.Duration
+	// Probe loads a members-only page in the browser to confirm the session
+	// is still accepted. Its answer overrides the cookie check.
+	Probe bool
+}
+
+// SessionStatus inspects the browser's cookies to report whether the session
+// is still usable. Schedulers can use it to log in ahead of time-sensitive
+// runs.
+//
+// Without Probe the browser isn't navigated and the state is a heuristic: it
+// only looks for the expected auth cookies and their expiry, which the site
+// may change without notice. Probe checks with the site itself by loading a
+// members-only page, a full page load that can take up to 30 seconds.
+func (s *Scraper) SessionStatus(opts SessionStatusOptions) (SessionStatus, error) {
+	return s.SessionStatusContext(context.Background(), opts)
+}
+
+// SessionStatusContext is SessionStatus bounded by ctx
+func (s *Scraper) SessionStatusContext(ctx context.Context, opts SessionStatusOptions) (SessionStatus, error) {
+	status := SessionStatus{State: SessionUnknown}
+
+	if opts.ExpiringWithin == 0 {
+		opts.ExpiringWithin = defaultSessionExpiringWithin
+	}
+
+	cookies, err := s.browser.Context(ctx).GetCookies()
+	if err != nil {
+		err = fmt.Errorf("failed to get cookies from browser: %w", err)
+		return status, err
+	}
+	status = cookieSessionStatus(cookies, time.Now(), opts.ExpiringWithin)
+
+	if !opts.Probe {
+		return status, nil
+	}
+
+	signedIn, err := s.probeSession(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to probe session: %w", err)
+		return status, err
+	}
+	status.Probed = true
+
+	switch {
+	case !signedIn:
+		status.State = SessionExpired
+	case status.State == SessionUnknown, status.State == SessionExpired:
+		// the cookies are only a guess; the site says the session works
+		status.State = SessionValid
+	}
+
+	return status, nil
+}
+
+// cookieSessionStatus guesses the session's state at now from its auth
+// cookies
+func cookieSessionStatus(cookies []*proto.NetworkCookie, now time.Time, expiringWithin time.Duration) SessionStatus {
+	status := SessionStatus{State: SessionUnknown}
+
+	for _, name := range authCookieNames {
+		cookie := findCookie(cookies, name)
+		if cookie == nil {
+			status.MissingCookies = append(status.MissingCookies, name)
+			continue
+		}
+
+		if cookie.Session || cookie.Expires <= 0 {
+			continue
+		}
+
+		expires := cookie.Expires.Time()
+		if status.ExpiresAt.IsZero() || expires.Before(status.ExpiresAt) {
+			status.ExpiresAt = expires
+		}
+	}
+
+	switch {
+	case len(status.MissingCookies) > 0:
+		status.State = SessionExpired
+	case status.ExpiresAt.IsZero():
+		status.State = SessionUnknown
+	case !status.ExpiresAt.After(now):
+		status.State = SessionExpired
+	case status.ExpiresAt.Sub(now) <= expiringWithin:
+		status.State = SessionExpiringSoon
+	default:
+		status.State = SessionValid
+	}
+
+	return status
+}
+
+func findCookie(cookies []*proto.NetworkCookie, name string) *proto.NetworkCookie {
+	for _, cookie := range cookies {
+		if cookie.Name == name && strings.HasSuffix(cookie.Domain, sessionCookieDomain) {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// probeSession loads a members-only page in a browser page and reports
+// whether it shows the dashboard rather than the sign in iframe. A signed out
+// session still gets a 200 for the page, so this is the same race
+// authenticatedNavigate runs.
+func (s *Scraper) probeSession(ctx context.Context) (bool, error) {
+	page, err := s.pages.get(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	signedIn, err := s.probeOn(ctx, page)
+	s.pages.put(page, err)
+	return signedIn, err
+}
+
+// probeOn runs probeSession's check on page
+func (s *Scraper) probeOn(ctx context.Context, page *rod.Page) (bool, error) {
+	err := s.waitRateLimit(ctx, EndpointNavigation)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sessionProbeTimeout)
+	defer cancel()
+	page = page.Context(ctx)
+
+	wait := waitNavigation(page)
+	err = page.Navigate(sessionProbeURL)
+	if err != nil {
+		err = fmt.Errorf("failed to navigate to '%s': %w", sessionProbeURL, err)
+		return false, err
+	}
+	wait()
+
+	signedIn := false
+	_, err = page.Race().Element(dashboardCheckSelector).Handle(func(*rod.Element) error {
+		signedIn = true
+		return nil
+	}).Element(signInIFrameSelector).Do()
+	if err != nil {
+		err = fmt.Errorf("failed to find dashboard or sign in form: %w", err)
+		return false, err
+	}
+
+	return signedIn, nil
+}
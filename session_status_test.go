@@ -0,0 +1,102 @@
+package dvcscraper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-rod/rod/lib/proto"
+)
+
+func TestCookieSessionStatus(t *testing.T) {
+	now := time.Date(2024, time.April, 2, 9, 0, 0, 0, time.UTC)
+	cookie := func(name string, expires time.Time) *proto.NetworkCookie {
+		c := &proto.NetworkCookie{Name: name, Domain: ".disney.go.com"}
+		if expires.IsZero() {
+			c.Session = true
+			c.Expires = -1
+		} else {
+			c.Expires = proto.TimeSinceEpoch(expires.Unix())
+		}
+		return c
+	}
+	session := time.Time{}
+
+	tests := []struct {
+		name        string
+		cookies     []*proto.NetworkCookie
+		wantState   SessionState
+		wantExpires time.Time
+		wantMissing []string
+	}{
+		{
+			name:        "no cookies",
+			wantState:   SessionExpired,
+			wantMissing: []string{"SWID", "pep_oauth_token"},
+		},
+		{
+			name:        "one missing",
+			cookies:     []*proto.NetworkCookie{cookie("SWID", now.Add(48*time.Hour))},
+			wantState:   SessionExpired,
+			wantExpires: now.Add(48 * time.Hour),
+			wantMissing: []string{"pep_oauth_token"},
+		},
+		{
+			name: "wrong domain",
+			cookies: []*proto.NetworkCookie{
+				cookie("SWID", now.Add(48*time.Hour)),
+				{Name: "pep_oauth_token", Domain: "example.com", Expires: proto.TimeSinceEpoch(now.Add(48 * time.Hour).Unix())},
+			},
+			wantState:   SessionExpired,
+			wantExpires: now.Add(48 * time.Hour),
+			wantMissing: []string{"pep_oauth_token"},
+		},
+		{
+			name:      "session cookies",
+			cookies:   []*proto.NetworkCookie{cookie("SWID", session), cookie("pep_oauth_token", session)},
+			wantState: SessionUnknown,
+		},
+		{
+			name:        "session cookie and expiring cookie",
+			cookies:     []*proto.NetworkCookie{cookie("SWID", session), cookie("pep_oauth_token", now.Add(2*time.Hour))},
+			wantState:   SessionValid,
+			wantExpires: now.Add(2 * time.Hour),
+		},
+		{
+			name:        "earliest expiry wins",
+			cookies:     []*proto.NetworkCookie{cookie("SWID", now.Add(48*time.Hour)), cookie("pep_oauth_token", now.Add(10*time.Minute))},
+			wantState:   SessionExpiringSoon,
+			wantExpires: now.Add(10 * time.Minute),
+		},
+		{
+			name:        "expiring exactly at the window",
+			cookies:     []*proto.NetworkCookie{cookie("SWID", now.Add(30*time.Minute)), cookie("pep_oauth_token", now.Add(time.Hour))},
+			wantState:   SessionExpiringSoon,
+			wantExpires: now.Add(30 * time.Minute),
+		},
+		{
+			name:        "expired",
+			cookies:     []*proto.NetworkCookie{cookie("SWID", now.Add(-time.Minute)), cookie("pep_oauth_token", now.Add(time.Hour))},
+			wantState:   SessionExpired,
+			wantExpires: now.Add(-time.Minute),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cookieSessionStatus(tt.cookies, now, 30*time.Minute)
+			if got.State != tt.wantState {
+				t.Fatalf("state %s, want %s", got.State, tt.wantState)
+			}
+			if !got.ExpiresAt.Equal(tt.wantExpires) {
+				t.Fatalf("expires %s, want %s", got.ExpiresAt, tt.wantExpires)
+			}
+			if !reflect.DeepEqual(got.MissingCookies, tt.wantMissing) {
+				t.Fatalf("missing %v, want %v", got.MissingCookies, tt.wantMissing)
+			}
+			if got.Probed {
+				t.Fatal("cookie check reported as probed")
+			}
+		})
+	}
+}
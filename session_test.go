@@ -0,0 +1,167 @@
+package dvcscraper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSessionStores(t *testing.T) {
+	tests := []struct {
+		name  string
+		store func(t *testing.T) SessionStore
+	}{
+		{name: "file", store: func(t *testing.T) SessionStore {
+			return NewFileSessionStore(filepath.Join(t.TempDir(), "nested", "session.json"))
+		}},
+		{name: "memory", store: func(t *testing.T) SessionStore {
+			return NewMemorySessionStore()
+		}},
+		{name: "dir", store: func(t *testing.T) SessionStore {
+			store, err := NewDirSessionStore(t.TempDir(), "alice")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return store
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := tt.store(t)
+
+			_, err := store.Load()
+			if !errors.Is(err, ErrNoSession) {
+				t.Fatalf("empty store: expected ErrNoSession, got %v", err)
+			}
+
+			err = store.Save([]byte(`[{"name":"a"}]`))
+			if err != nil {
+				t.Fatalf("failed to save: %v", err)
+			}
+			err = store.Save([]byte(`[{"name":"b"}]`))
+			if err != nil {
+				t.Fatalf("failed to replace: %v", err)
+			}
+
+			raw, err := store.Load()
+			if err != nil {
+				t.Fatalf("failed to load: %v", err)
+			}
+			if string(raw) != `[{"name":"b"}]` {
+				t.Fatalf("loaded %q, want the last save", raw)
+			}
+
+			err = store.Delete()
+			if err != nil {
+				t.Fatalf("failed to delete: %v", err)
+			}
+			_, err = store.Load()
+			if !errors.Is(err, ErrNoSession) {
+				t.Fatalf("deleted store: expected ErrNoSession, got %v", err)
+			}
+
+			err = store.Delete()
+			if err != nil {
+				t.Fatalf("deleting a missing session: %v", err)
+			}
+		})
+	}
+}
+
+func TestMemorySessionStoreCopies(t *testing.T) {
+	store := NewMemorySessionStore()
+	raw := []byte("session")
+	_ = store.Save(raw)
+	raw[0] = 'X'
+
+	loaded, _ := store.Load()
+	if string(loaded) != "session" {
+		t.Fatalf("save kept the caller's slice: %q", loaded)
+	}
+	loaded[0] = 'Y'
+
+	again, _ := store.Load()
+	if string(again) != "session" {
+		t.Fatalf("load returned the stored slice: %q", again)
+	}
+}
+
+func TestFileSessionStorePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes aren't enforced on windows")
+	}
+
+	dir := t.TempDir()
+	store := NewFileSessionStore(filepath.Join(dir, "session.json"))
+	err := store.Save([]byte("session"))
+	if err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	info, err := os.Stat(store.Path)
+	if err != nil {
+		t.Fatalf("failed to stat: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("session file mode %o, want 600", info.Mode().Perm())
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestNewDirSessionStore(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		account  string
+		wantPath string
+		wantErr  bool
+	}{
+		{account: "alice", wantPath: filepath.Join(dir, "alice.json")},
+		{account: "bob@example.com", wantPath: filepath.Join(dir, "bob@example.com.json")},
+		{account: "", wantErr: true},
+		{account: ".", wantErr: true},
+		{account: "..", wantErr: true},
+		{account: "../alice", wantErr: true},
+		{account: "a/b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.account, func(t *testing.T) {
+			store, err := NewDirSessionStore(dir, tt.account)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", store.Path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if store.Path != tt.wantPath {
+				t.Fatalf("got %s, want %s", store.Path, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestDirSessionStoresAreSeparate(t *testing.T) {
+	dir := t.TempDir()
+	alice, _ := NewDirSessionStore(dir, "alice")
+	bob, _ := NewDirSessionStore(dir, "bob")
+
+	_ = alice.Save([]byte("alice"))
+	_, err := bob.Load()
+	if !errors.Is(err, ErrNoSession) {
+		t.Fatalf("bob sees alice's session: %v", err)
+	}
+}
@@ -3,4 +3,4 @@
 set -e
 
 rm -f *.png
-go run cmd/main.go
+go run ./cmd screenshot --out dashboard.png --wait .news-alert-header https://disneyvacationclub.disney.go.com/home/
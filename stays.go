@@ -0,0 +1,202 @@
+package dvcscraper
+
+import (
+	"sort"
+	"time"
+
+	"github.com/lineleader/dvc-scraper/catalog"
+)
+
+// StaySort orders stays returned by the stay finders
+type StaySort int
+
+const (
+	// SortByPoints puts the cheapest stays first
+	SortByPoints StaySort = iota
+	// SortByCheckIn puts the earliest stays first
+	SortByCheckIn
+)
+
+// Stay is a run of consecutive nights that can all be booked
+type Stay struct {
+	// CheckIn is the first night, as YYYY-MM-DD
+	CheckIn string `json:"checkIn"`
+	// CheckOut is the morning after the last night, as YYYY-MM-DD
+	CheckOut string `json:"checkOut"`
+	Nights   int    `json:"nights"`
+	Points   int    `json:"points"`
+	// Segments has one entry per room type; more than one for split stays
+	Segments []StaySegment `json:"segments"`
+}
+
+// StaySegment is the part of a stay spent in one room type
+type StaySegment struct {
+	Resort   catalog.ResortCode   `json:"resort"`
+	RoomType catalog.RoomTypeCode `json:"roomType"`
+	CheckIn  string               `json:"checkIn"`
+	CheckOut string               `json:"checkOut"`
+	Nights   int                  `json:"nights"`
+	Points   int                  `json:"points"`
+}
+
+// Split reports whether the stay moves between room types
+func (s Stay) Split() bool {
+	return len(s.Segments) > 1
+}
+
+// FindStays returns every run of minNights to maxNights consecutive nights
+// that all have rooms and cost at most maxPoints in total. A maxNights or
+// maxPoints of zero means no limit. Stays are sorted by points.
+func FindStays(results AvailabilityResults, minNights, maxNights, maxPoints int) []Stay {
+	stays := []Stay{}
+	nights := openNightsByDate(results)
+
+	for _, start := range sortedDates(nights) {
+		segment := StaySegment{Resort: results.ResortCode, RoomType: results.RoomCode, CheckIn: start.Format(dateFormat)}
+		for day := start; ; day = day.AddDate(0, 0, 1) {
+			night, ok := nights[day.Format(dateFormat)]
+			if !ok || !withinNights(segment.Nights+1, maxNights) || !withinPoints(segment.Points+night.Points, maxPoints) {
+				break
+			}
+			segment.Nights++
+			segment.Points += night.Points
+			segment.CheckOut = day.AddDate(0, 0, 1).Format(dateFormat)
+
+			if segment.Nights >= minNights {
+				stays = append(stays, newStay(segment))
+			}
+		}
+	}
+
+	SortStays(stays, SortByPoints)
+	return stays
+}
+
+// FindSplitStays is FindStays across several room types, also returning
+// stays that start in one room type and move to another part way through.
+// Only one move per stay is considered.
+func FindSplitStays(results []AvailabilityResults, minNights, maxNights, maxPoints int) []Stay {
+	stays := []Stay{}
+	for _, result := range results {
+		stays = append(stays, FindStays(result, minNights, maxNights, maxPoints)...)
+	}
+
+	for i, first := range results {
+		firstNights := openNightsByDate(first)
+		for j, second := range results {
+			if i == j {
+				continue
+			}
+			secondNights := openNightsByDate(second)
+
+			for _, start := range sortedDates(firstNights) {
+				head := StaySegment{Resort: first.ResortCode, RoomType: first.RoomCode, CheckIn: start.Format(dateFormat)}
+				for day := start; ; day = day.AddDate(0, 0, 1) {
+					night, ok := firstNights[day.Format(dateFormat)]
+					if !ok || !withinNights(head.Nights+2, maxNights) || !withinPoints(head.Points+night.Points, maxPoints) {
+						break
+					}
+					head.Nights++
+					head.Points += night.Points
+					head.CheckOut = day.AddDate(0, 0, 1).Format(dateFormat)
+
+					stays = append(stays, splitStays(head, second, secondNights, minNights, maxNights, maxPoints)...)
+				}
+			}
+		}
+	}
+
+	SortStays(stays, SortByPoints)
+	return stays
+}
+
+// splitStays returns the stays made of head followed by nights in second
+func splitStays(head StaySegment, second AvailabilityResults, nights map[string]DayAvailability, minNights, maxNights, maxPoints int) []Stay {
+	stays := []Stay{}
+
+	tail := StaySegment{Resort: second.ResortCode, RoomType: second.RoomCode, CheckIn: head.CheckOut}
+	start, err := time.Parse(dateFormat, head.CheckOut)
+	if err != nil {
+		return stays
+	}
+
+	for day := start; ; day = day.AddDate(0, 0, 1) {
+		night, ok := nights[day.Format(dateFormat)]
+		total := head.Nights + tail.Nights + 1
+		if !ok || !withinNights(total, maxNights) || !withinPoints(head.Points+tail.Points+night.Points, maxPoints) {
+			break
+		}
+		tail.Nights++
+		tail.Points += night.Points
+		tail.CheckOut = day.AddDate(0, 0, 1).Format(dateFormat)
+
+		if total >= minNights {
+			stays = append(stays, newStay(head, tail))
+		}
+	}
+
+	return stays
+}
+
+// SortStays sorts stays in place, breaking ties by check in and then length
+func SortStays(stays []Stay, by StaySort) {
+	sort.SliceStable(stays, func(i, j int) bool {
+		a, b := stays[i], stays[j]
+		if by == SortByPoints && a.Points != b.Points {
+			return a.Points < b.Points
+		}
+		if a.CheckIn != b.CheckIn {
+			return a.CheckIn < b.CheckIn
+		}
+		if by == SortByCheckIn && a.Points != b.Points {
+			return a.Points < b.Points
+		}
+		return a.Nights < b.Nights
+	})
+}
+
+func newStay(segments ...StaySegment) Stay {
+	stay := Stay{
+		CheckIn:  segments[0].CheckIn,
+		CheckOut: segments[len(segments)-1].CheckOut,
+		Segments: segments,
+	}
+	for _, segment := range segments {
+		stay.Nights += segment.Nights
+		stay.Points += segment.Points
+	}
+	return stay
+}
+
+func openNightsByDate(results AvailabilityResults) map[string]DayAvailability {
+	nights := map[string]DayAvailability{}
+	for _, night := range results.Availability {
+		if night.Rooms > 0 {
+			nights[night.Day()] = night
+		}
+	}
+	return nights
+}
+
+func sortedDates(nights map[string]DayAvailability) []time.Time {
+	dates := []time.Time{}
+	for day := range nights {
+		date, err := time.Parse(dateFormat, day)
+		if err != nil {
+			continue
+		}
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool {
+		return dates[i].Before(dates[j])
+	})
+	return dates
+}
+
+func withinNights(nights, maxNights int) bool {
+	return maxNights <= 0 || nights <= maxNights
+}
+
+func withinPoints(points, maxPoints int) bool {
+	return maxPoints <= 0 || points <= maxPoints
+}
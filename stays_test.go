@@ -0,0 +1,184 @@
+package dvcscraper
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// staySummary is a compact form of a stay for comparing in tests
+func staySummary(stay Stay) string {
+	summary := fmt.Sprintf("%s-%s %dn %dp", stay.CheckIn, stay.CheckOut, stay.Nights, stay.Points)
+	for _, segment := range stay.Segments {
+		summary += fmt.Sprintf(" [%s %s %dn]", segment.RoomType, segment.CheckIn, segment.Nights)
+	}
+	return summary
+}
+
+func staySummaries(stays []Stay) []string {
+	summaries := []string{}
+	for _, stay := range stays {
+		summaries = append(summaries, staySummary(stay))
+	}
+	return summaries
+}
+
+func TestFindStays(t *testing.T) {
+	results := AvailabilityResults{
+		ResortCode: "BLT",
+		RoomCode:   "A",
+		Availability: []DayAvailability{
+			{Date: "2024-04-01T00:00:00", Rooms: 1, Points: 10},
+			{Date: "2024-04-02", Rooms: 2, Points: 12},
+			{Date: "2024-04-03", Rooms: 0, Points: 14},
+			{Date: "2024-04-04", Rooms: 1, Points: 8},
+		},
+	}
+
+	tests := []struct {
+		name                string
+		min, max, maxPoints int
+		want                []string
+	}{
+		{
+			name: "every run",
+			min:  1,
+			want: []string{
+				"2024-04-04-2024-04-05 1n 8p [A 2024-04-04 1n]",
+				"2024-04-01-2024-04-02 1n 10p [A 2024-04-01 1n]",
+				"2024-04-02-2024-04-03 1n 12p [A 2024-04-02 1n]",
+				"2024-04-01-2024-04-03 2n 22p [A 2024-04-01 2n]",
+			},
+		},
+		{
+			name: "minimum nights skip closed night",
+			min:  2,
+			want: []string{"2024-04-01-2024-04-03 2n 22p [A 2024-04-01 2n]"},
+		},
+		{
+			name: "maximum nights",
+			min:  1, max: 1,
+			want: []string{
+				"2024-04-04-2024-04-05 1n 8p [A 2024-04-04 1n]",
+				"2024-04-01-2024-04-02 1n 10p [A 2024-04-01 1n]",
+				"2024-04-02-2024-04-03 1n 12p [A 2024-04-02 1n]",
+			},
+		},
+		{
+			name: "maximum points",
+			min:  1, maxPoints: 11,
+			want: []string{
+				"2024-04-04-2024-04-05 1n 8p [A 2024-04-04 1n]",
+				"2024-04-01-2024-04-02 1n 10p [A 2024-04-01 1n]",
+			},
+		},
+		{
+			name: "nothing long enough",
+			min:  3,
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := staySummaries(FindStays(results, tt.min, tt.max, tt.maxPoints))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindSplitStays(t *testing.T) {
+	first := AvailabilityResults{
+		ResortCode: "BLT",
+		RoomCode:   "A",
+		Availability: []DayAvailability{
+			{Date: "2024-04-01", Rooms: 1, Points: 10},
+		},
+	}
+	second := AvailabilityResults{
+		ResortCode: "BLT",
+		RoomCode:   "B",
+		Availability: []DayAvailability{
+			{Date: "2024-04-02", Rooms: 1, Points: 5},
+			{Date: "2024-04-03", Rooms: 1, Points: 5},
+		},
+	}
+
+	tests := []struct {
+		name                string
+		min, max, maxPoints int
+		want                []string
+	}{
+		{
+			name: "split needed for length",
+			min:  3,
+			want: []string{"2024-04-01-2024-04-04 3n 20p [A 2024-04-01 1n] [B 2024-04-02 2n]"},
+		},
+		{
+			name: "split and single room stays",
+			min:  2,
+			want: []string{
+				"2024-04-02-2024-04-04 2n 10p [B 2024-04-02 2n]",
+				"2024-04-01-2024-04-03 2n 15p [A 2024-04-01 1n] [B 2024-04-02 1n]",
+				"2024-04-01-2024-04-04 3n 20p [A 2024-04-01 1n] [B 2024-04-02 2n]",
+			},
+		},
+		{
+			name: "maximum nights",
+			min:  3, max: 2,
+			want: []string{},
+		},
+		{
+			name: "maximum points",
+			min:  3, maxPoints: 15,
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stays := FindSplitStays([]AvailabilityResults{first, second}, tt.min, tt.max, tt.maxPoints)
+			got := staySummaries(stays)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+			for _, stay := range stays {
+				if stay.Split() != (len(stay.Segments) > 1) {
+					t.Fatalf("Split() wrong for %s", staySummary(stay))
+				}
+			}
+		})
+	}
+}
+
+func TestSortStays(t *testing.T) {
+	stays := []Stay{
+		{CheckIn: "2024-04-03", Nights: 1, Points: 10},
+		{CheckIn: "2024-04-01", Nights: 2, Points: 20},
+		{CheckIn: "2024-04-01", Nights: 1, Points: 10},
+		{CheckIn: "2024-04-02", Nights: 1, Points: 5},
+	}
+
+	tests := []struct {
+		by   StaySort
+		want []string
+	}{
+		{by: SortByPoints, want: []string{"2024-04-02/5", "2024-04-01/10", "2024-04-03/10", "2024-04-01/20"}},
+		{by: SortByCheckIn, want: []string{"2024-04-01/10", "2024-04-01/20", "2024-04-02/5", "2024-04-03/10"}},
+	}
+
+	for _, tt := range tests {
+		sorted := append([]Stay{}, stays...)
+		SortStays(sorted, tt.by)
+
+		got := []string{}
+		for _, stay := range sorted {
+			got = append(got, fmt.Sprintf("%s/%d", stay.CheckIn, stay.Points))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("sort %d: got %q, want %q", tt.by, got, tt.want)
+		}
+	}
+}
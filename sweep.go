@@ -0,0 +1,179 @@
+package dvcscraper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lineleader/dvc-scraper/catalog"
+)
+
+// SweepOptions configure a search across resorts and room types. Empty
+// filters match every catalog room type.
+type SweepOptions struct {
+	Start time.Time
+	End   time.Time
+
+	// Resorts limits the sweep to these resorts
+	Resorts []catalog.ResortCode
+	// RoomTypes limits the sweep to these room type codes
+	RoomTypes []catalog.RoomTypeCode
+	// MinCapacity skips room types sleeping fewer people
+	MinCapacity int
+	// Views limits the sweep to these views
+	Views []catalog.View
+
+	// OnlyOpen drops nights without rooms, and room types without any
+	// open nights, from the results
+	OnlyOpen bool
+}
+
+// SweepFailure is a room type that couldn't be searched during a sweep
+type SweepFailure struct {
+	RoomType catalog.RoomType
+	Err      error
+}
+
+// SweepErrors is returned alongside the results when some room types in a
+// sweep failed
+type SweepErrors []SweepFailure
+
+func (s SweepErrors) Error() string {
+	msgs := make([]string, 0, len(s))
+	for _, failure := range s {
+		msgs = append(msgs, fmt.Sprintf("%s %s: %s", failure.RoomType.Resort, failure.RoomType.Code, failure.Err.Error()))
+	}
+	return fmt.Sprintf("%d room types failed: %s", len(s), strings.Join(msgs, "; "))
+}
+
+// SweepAvailability searches every room type matching opts for the date range
+func (h *AvailabilityHandle) SweepAvailability(opts SweepOptions) ([]AvailabilityResults, error) {
+	return h.SweepAvailabilityContext(context.Background(), opts)
+}
+
+// SweepAvailabilityContext is SweepAvailability bounded by ctx.
+//
+// A room type that fails doesn't stop the sweep; the results for the rest are
+// returned along with SweepErrors describing the failures.
+func (h *AvailabilityHandle) SweepAvailabilityContext(ctx context.Context, opts SweepOptions) ([]AvailabilityResults, error) {
+	all := []AvailabilityResults{}
+	failures := SweepErrors{}
+
+	for _, roomType := range opts.MatchingRoomTypes() {
+		results, err := h.GetAvailabilityRangeContext(ctx, AvailabilityRangeOptions{
+			Resort:   roomType.Resort,
+			RoomType: roomType.Code,
+			Start:    opts.Start,
+			End:      opts.End,
+		})
+		if ctx.Err() != nil {
+			return all, ctx.Err()
+		}
+		if err != nil {
+			failures = append(failures, SweepFailure{RoomType: roomType, Err: err})
+			continue
+		}
+
+		if opts.OnlyOpen {
+			results.Availability = openNights(results.Availability)
+			if len(results.Availability) == 0 {
+				continue
+			}
+		}
+
+		all = append(all, results)
+	}
+
+	if len(failures) > 0 {
+		return all, failures
+	}
+	return all, nil
+}
+
+// MatchingRoomTypes returns the room types the sweep will search: the catalog
+// room types matching the filters, plus every room type code in RoomTypes at
+// every resort in Resorts that the catalog doesn't list. Capacity and view
+// aren't known for those, so MinCapacity and Views don't apply to them.
+//
+// With no Resorts or no RoomTypes only catalog room types are searched, so a
+// sweep never spends requests on guessed codes.
+func (o SweepOptions) MatchingRoomTypes() []catalog.RoomType {
+	return filterRoomTypes(catalog.RoomTypes(), o)
+}
+
+func filterRoomTypes(roomTypes []catalog.RoomType, opts SweepOptions) []catalog.RoomType {
+	matched := []catalog.RoomType{}
+	for _, roomType := range roomTypes {
+		if len(opts.Resorts) > 0 && !hasResort(opts.Resorts, roomType.Resort) {
+			continue
+		}
+		if len(opts.RoomTypes) > 0 && !hasRoomType(opts.RoomTypes, roomType.Code) {
+			continue
+		}
+		if roomType.Capacity < opts.MinCapacity {
+			continue
+		}
+		if len(opts.Views) > 0 && !hasView(opts.Views, roomType.View) {
+			continue
+		}
+		matched = append(matched, roomType)
+	}
+
+	for _, resort := range opts.Resorts {
+		for _, code := range opts.RoomTypes {
+			if listed(roomTypes, resort, code) || listed(matched, resort, code) {
+				continue
+			}
+			matched = append(matched, catalog.RoomType{Resort: resort, Code: code})
+		}
+	}
+	return matched
+}
+
+// listed reports whether roomTypes has code at resort
+func listed(roomTypes []catalog.RoomType, resort catalog.ResortCode, code catalog.RoomTypeCode) bool {
+	for _, roomType := range roomTypes {
+		if strings.EqualFold(string(roomType.Resort), string(resort)) && strings.EqualFold(string(roomType.Code), string(code)) {
+			return true
+		}
+	}
+	return false
+}
+
+func openNights(nights []DayAvailability) []DayAvailability {
+	open := []DayAvailability{}
+	for _, night := range nights {
+		if night.Rooms > 0 {
+			open = append(open, night)
+		}
+	}
+	return open
+}
+
+func hasResort(resorts []catalog.ResortCode, code catalog.ResortCode) bool {
+	for _, resort := range resorts {
+		if strings.EqualFold(string(resort), string(code)) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasRoomType(roomTypes []catalog.RoomTypeCode, code catalog.RoomTypeCode) bool {
+	for _, roomType := range roomTypes {
+		if strings.EqualFold(string(roomType), string(code)) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasView(views []catalog.View, view catalog.View) bool {
+	for _, v := range views {
+		if v == view {
+			return true
+		}
+	}
+	return false
+}
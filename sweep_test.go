@@ -0,0 +1,109 @@
+package dvcscraper
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lineleader/dvc-scraper/catalog"
+)
+
+func TestFilterRoomTypes(t *testing.T) {
+	roomTypes := []catalog.RoomType{
+		{Resort: "AAA", Code: "ST", Capacity: 4, View: catalog.ViewStandard},
+		{Resort: "AAA", Code: "1BL", Capacity: 5, Bedrooms: 1, View: catalog.ViewLake},
+		{Resort: "BBB", Code: "ST", Capacity: 4, View: catalog.ViewStandard},
+		{Resort: "BBB", Code: "GV", Capacity: 12, Bedrooms: 3, View: catalog.ViewThemePark},
+	}
+
+	tests := []struct {
+		name string
+		opts SweepOptions
+		want []string
+	}{
+		{
+			name: "everything",
+			want: []string{"AAA ST", "AAA 1BL", "BBB ST", "BBB GV"},
+		},
+		{
+			name: "resort",
+			opts: SweepOptions{Resorts: []catalog.ResortCode{"bbb"}},
+			want: []string{"BBB ST", "BBB GV"},
+		},
+		{
+			name: "room type",
+			opts: SweepOptions{RoomTypes: []catalog.RoomTypeCode{"st"}},
+			want: []string{"AAA ST", "BBB ST"},
+		},
+		{
+			name: "capacity",
+			opts: SweepOptions{MinCapacity: 5},
+			want: []string{"AAA 1BL", "BBB GV"},
+		},
+		{
+			name: "views",
+			opts: SweepOptions{Views: []catalog.View{catalog.ViewLake, catalog.ViewThemePark}},
+			want: []string{"AAA 1BL", "BBB GV"},
+		},
+		{
+			name: "resort without catalog room types",
+			opts: SweepOptions{Resorts: []catalog.ResortCode{"CCC"}},
+			want: []string{},
+		},
+		{
+			name: "unlisted room type needs a resort",
+			opts: SweepOptions{RoomTypes: []catalog.RoomTypeCode{"XX"}},
+			want: []string{},
+		},
+		{
+			name: "unlisted codes at named resorts",
+			opts: SweepOptions{
+				Resorts:   []catalog.ResortCode{"AAA", "CCC"},
+				RoomTypes: []catalog.RoomTypeCode{"ST", "XX"},
+			},
+			want: []string{"AAA ST", "AAA XX", "CCC ST", "CCC XX"},
+		},
+		{
+			name: "filters skip unlisted codes only when listed",
+			opts: SweepOptions{
+				Resorts:     []catalog.ResortCode{"AAA"},
+				RoomTypes:   []catalog.RoomTypeCode{"ST", "XX"},
+				MinCapacity: 5,
+			},
+			want: []string{"AAA XX"},
+		},
+		{
+			name: "repeated codes",
+			opts: SweepOptions{
+				Resorts:   []catalog.ResortCode{"CCC", "ccc"},
+				RoomTypes: []catalog.RoomTypeCode{"XX", "xx"},
+			},
+			want: []string{"CCC XX"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, roomType := range filterRoomTypes(roomTypes, tt.opts) {
+				got = append(got, string(roomType.Resort)+" "+string(roomType.Code))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenNights(t *testing.T) {
+	nights := []DayAvailability{
+		{Date: "2024-04-01", Rooms: 1},
+		{Date: "2024-04-02", Rooms: 0},
+		{Date: "2024-04-03", Rooms: 3},
+	}
+
+	got := openNights(nights)
+	want := []DayAvailability{nights[0], nights[2]}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,367 @@
+package dvcscraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lineleader/dvc-scraper/catalog"
+)
+
+const (
+	defaultWatchInterval = 15 * time.Minute
+	defaultEventBuffer   = 100
+)
+
+// AvailabilityEventKind says what changed about a night
+type AvailabilityEventKind string
+
+// Kinds of availability change
+const (
+	NightOpened   AvailabilityEventKind = "night_opened"
+	NightClosed   AvailabilityEventKind = "night_closed"
+	RoomsChanged  AvailabilityEventKind = "rooms_changed"
+	PointsChanged AvailabilityEventKind = "points_changed"
+)
+
+// AvailabilityEvent describes a change to one night between two polls
+type AvailabilityEvent struct {
+	Kind     AvailabilityEventKind `json:"kind"`
+	Resort   catalog.ResortCode    `json:"resort"`
+	RoomType catalog.RoomTypeCode  `json:"roomType"`
+	Date     string                `json:"date"`
+	Previous DayAvailability       `json:"previous"`
+	Current  DayAvailability       `json:"current"`
+	// ObservedAt is when the poll that found the change ran
+	ObservedAt time.Time `json:"observedAt"`
+}
+
+// SnapshotStore persists the last results seen by a Watcher, keyed by request
+type SnapshotStore interface {
+	LoadSnapshots() (map[string]AvailabilityResults, error)
+	SaveSnapshots(map[string]AvailabilityResults) error
+}
+
+// FileSnapshotStore keeps snapshots in a JSON file
+type FileSnapshotStore struct {
+	Path string
+}
+
+// LoadSnapshots reads the file; a missing file is an empty set of snapshots
+func (f FileSnapshotStore) LoadSnapshots() (map[string]AvailabilityResults, error) {
+	snapshots := map[string]AvailabilityResults{}
+
+	raw, err := os.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return snapshots, nil
+	} else if err != nil {
+		err = fmt.Errorf("failed to read snapshot file: %w", err)
+		return snapshots, err
+	}
+
+	err = json.Unmarshal(raw, &snapshots)
+	if err != nil {
+		err = fmt.Errorf("failed to unmarshal snapshots: %w", err)
+		return snapshots, err
+	}
+
+	return snapshots, nil
+}
+
+// SaveSnapshots replaces the file
+func (f FileSnapshotStore) SaveSnapshots(snapshots map[string]AvailabilityResults) error {
+	raw, err := json.Marshal(snapshots)
+	if err != nil {
+		err = fmt.Errorf("failed to marshal snapshots: %w", err)
+		return err
+	}
+
+	tmp := f.Path + ".tmp"
+	err = os.MkdirAll(filepath.Dir(f.Path), 0700)
+	if err != nil {
+		err = fmt.Errorf("failed to create snapshot directory: %w", err)
+		return err
+	}
+
+	err = os.WriteFile(tmp, raw, 0600)
+	if err != nil {
+		err = fmt.Errorf("failed to write snapshot file: %w", err)
+		return err
+	}
+
+	err = os.Rename(tmp, f.Path)
+	if err != nil {
+		err = fmt.Errorf("failed to replace snapshot file: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// MemorySnapshotStore keeps snapshots in memory only
+type MemorySnapshotStore struct {
+	mu        sync.Mutex
+	snapshots map[string]AvailabilityResults
+}
+
+// LoadSnapshots returns the saved snapshots
+func (m *MemorySnapshotStore) LoadSnapshots() (map[string]AvailabilityResults, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshots := map[string]AvailabilityResults{}
+	for key, results := range m.snapshots {
+		snapshots[key] = results
+	}
+	return snapshots, nil
+}
+
+// SaveSnapshots replaces the saved snapshots
+func (m *MemorySnapshotStore) SaveSnapshots(snapshots map[string]AvailabilityResults) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.snapshots = map[string]AvailabilityResults{}
+	for key, results := range snapshots {
+		m.snapshots[key] = results
+	}
+	return nil
+}
+
+// WatcherOptions configure a Watcher
+type WatcherOptions struct {
+	// Requests are re-run on every poll
+	Requests []AvailabilityOptions
+	// Interval between polls. Defaults to 15 minutes.
+	Interval time.Duration
+	// Snapshots persists results between runs so a restart only reports real
+	// changes. Defaults to memory only.
+	Snapshots SnapshotStore
+	// EmitInitial reports every open night of a request the first time it's
+	// polled. Otherwise the first poll only records a baseline.
+	EmitInitial bool
+	// EventBuffer is the size of the events channel. Defaults to 100.
+	EventBuffer int
+}
+
+// Watcher polls availability and reports what changed between polls
+type Watcher struct {
+	handle *AvailabilityHandle
+	opts   WatcherOptions
+	logger *log.Logger
+
+	events chan AvailabilityEvent
+
+	// mu serializes polls, which share the handle and the snapshots
+	mu        sync.Mutex
+	snapshots map[string]AvailabilityResults
+}
+
+// NewWatcher returns a Watcher polling through handle
+func NewWatcher(handle *AvailabilityHandle, opts WatcherOptions) (*Watcher, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultWatchInterval
+	}
+	if opts.Snapshots == nil {
+		opts.Snapshots = &MemorySnapshotStore{}
+	}
+	if opts.EventBuffer <= 0 {
+		opts.EventBuffer = defaultEventBuffer
+	}
+
+	snapshots, err := opts.Snapshots.LoadSnapshots()
+	if err != nil {
+		err = fmt.Errorf("failed to load snapshots: %w", err)
+		return nil, err
+	}
+
+	logger := log.Default()
+	if handle.scraper != nil {
+		logger = handle.scraper.logger
+	}
+
+	return &Watcher{
+		handle:    handle,
+		opts:      opts,
+		logger:    logger,
+		events:    make(chan AvailabilityEvent, opts.EventBuffer),
+		snapshots: snapshots,
+	}, nil
+}
+
+// Events returns the channel events are sent on. It's closed when Run returns.
+func (w *Watcher) Events() <-chan AvailabilityEvent {
+	return w.events
+}
+
+// Run polls immediately and then every interval until ctx is done. Failed
+// polls are logged and retried at the next interval.
+func (w *Watcher) Run(ctx context.Context) error {
+	defer close(w.events)
+
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		events, err := w.Poll(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			w.logger.Println("availability poll failed:", err)
+		}
+
+		for _, event := range events {
+			select {
+			case w.events <- event:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll runs every request once, returns the changes since the last poll and
+// saves the new snapshots. A failed request keeps its old snapshot. Events
+// from Poll are not sent on the Events channel; only Run does that. Poll may
+// be called while Run is running; the two take turns.
+func (w *Watcher) Poll(ctx context.Context) ([]AvailabilityEvent, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	all := []AvailabilityEvent{}
+	var firstErr error
+
+	now := time.Now()
+	for _, req := range w.opts.Requests {
+		key := snapshotKey(req)
+
+		results, err := w.handle.GetAvailabilityContext(ctx, req)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to get availability for %s: %w", key, err)
+			}
+			continue
+		}
+
+		previous, seen := w.snapshots[key]
+		w.snapshots[key] = mergeSnapshot(previous, results, now)
+		if !seen && !w.opts.EmitInitial {
+			continue
+		}
+
+		all = append(all, DiffAvailability(previous, results, now)...)
+	}
+
+	err := w.opts.Snapshots.SaveSnapshots(w.snapshots)
+	if err != nil {
+		err = fmt.Errorf("failed to save snapshots: %w", err)
+		return all, err
+	}
+
+	return all, firstErr
+}
+
+// DiffAvailability compares two results for the same resort and room type.
+// Only nights in current are compared: a night missing from it, as happens
+// with a partial result, is unknown rather than closed. Nights before the day
+// of observedAt are ignored since they drop out of results as time passes.
+func DiffAvailability(previous, current AvailabilityResults, observedAt time.Time) []AvailabilityEvent {
+	events := []AvailabilityEvent{}
+	today := observedAt.Format(dateFormat)
+
+	prevNights := nightsByDate(previous)
+	curNights := nightsByDate(current)
+
+	dates := []string{}
+	for date := range curNights {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	for _, date := range dates {
+		if date < today {
+			continue
+		}
+
+		prev, cur := prevNights[date], curNights[date]
+		event := AvailabilityEvent{
+			Resort:     current.ResortCode,
+			RoomType:   current.RoomCode,
+			Date:       date,
+			Previous:   prev,
+			Current:    cur,
+			ObservedAt: observedAt,
+		}
+		if event.Resort == "" {
+			event.Resort = previous.ResortCode
+			event.RoomType = previous.RoomCode
+		}
+
+		switch {
+		case prev.Rooms <= 0 && cur.Rooms > 0:
+			event.Kind = NightOpened
+			events = append(events, event)
+		case prev.Rooms > 0 && cur.Rooms <= 0:
+			event.Kind = NightClosed
+			events = append(events, event)
+		case prev.Rooms > 0 && cur.Rooms > 0:
+			if prev.Rooms != cur.Rooms {
+				event.Kind = RoomsChanged
+				events = append(events, event)
+			}
+			if prev.Points != cur.Points {
+				event.Kind = PointsChanged
+				events = append(events, event)
+			}
+		}
+	}
+
+	return events
+}
+
+// mergeSnapshot returns current with the nights it's missing filled in from
+// previous, so a partial result doesn't make those nights look new when they
+// come back. Nights before the day of observedAt are dropped.
+func mergeSnapshot(previous, current AvailabilityResults, observedAt time.Time) AvailabilityResults {
+	today := observedAt.Format(dateFormat)
+	nights := nightsByDate(previous)
+	for day, night := range nightsByDate(current) {
+		nights[day] = night
+	}
+
+	merged := current
+	merged.Availability = []DayAvailability{}
+	for day, night := range nights {
+		if day >= today {
+			merged.Availability = append(merged.Availability, night)
+		}
+	}
+	sortNights(merged.Availability)
+
+	return merged
+}
+
+func nightsByDate(results AvailabilityResults) map[string]DayAvailability {
+	nights := map[string]DayAvailability{}
+	for _, night := range results.Availability {
+		nights[night.Day()] = night
+	}
+	return nights
+}
+
+func snapshotKey(opts AvailabilityOptions) string {
+	return fmt.Sprintf("%s/%s/%s", opts.Resort, opts.RoomType, opts.Date.Format("2006-01"))
+}
@@ -0,0 +1,123 @@
+package dvcscraper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffAvailability(t *testing.T) {
+	observedAt := time.Date(2024, time.April, 2, 9, 0, 0, 0, time.UTC)
+	results := func(nights ...DayAvailability) AvailabilityResults {
+		return AvailabilityResults{ResortCode: "BLT", RoomCode: "4O", Availability: nights}
+	}
+	night := func(date string, rooms, points int) DayAvailability {
+		return DayAvailability{Date: date, Rooms: rooms, Points: points}
+	}
+
+	tests := []struct {
+		name     string
+		previous AvailabilityResults
+		current  AvailabilityResults
+		want     []string
+	}{
+		{
+			name:     "opened",
+			previous: results(night("2024-04-05", 0, 20)),
+			current:  results(night("2024-04-05", 1, 20)),
+			want:     []string{"2024-04-05 night_opened"},
+		},
+		{
+			name:     "closed",
+			previous: results(night("2024-04-05", 2, 20)),
+			current:  results(night("2024-04-05", 0, 20)),
+			want:     []string{"2024-04-05 night_closed"},
+		},
+		{
+			name:     "new open night",
+			previous: results(),
+			current:  results(night("2024-04-05", 1, 20)),
+			want:     []string{"2024-04-05 night_opened"},
+		},
+		{
+			name:     "missing from partial result",
+			previous: results(night("2024-04-05", 2, 20), night("2024-04-06", 2, 20)),
+			current:  results(night("2024-04-05", 2, 20)),
+			want:     []string{},
+		},
+		{
+			name:     "rooms and points changed",
+			previous: results(night("2024-04-05", 2, 20)),
+			current:  results(night("2024-04-05", 1, 25)),
+			want:     []string{"2024-04-05 rooms_changed", "2024-04-05 points_changed"},
+		},
+		{
+			name:     "unchanged",
+			previous: results(night("2024-04-05", 2, 20), night("2024-04-06", 0, 20)),
+			current:  results(night("2024-04-05", 2, 20), night("2024-04-06", 0, 20)),
+			want:     []string{},
+		},
+		{
+			name:     "past nights ignored",
+			previous: results(night("2024-04-01", 0, 20)),
+			current:  results(night("2024-04-01", 1, 20)),
+			want:     []string{},
+		},
+		{
+			name:     "sorted by date",
+			previous: results(),
+			current:  results(night("2024-04-07T00:00:00", 1, 20), night("2024-04-03", 1, 20)),
+			want:     []string{"2024-04-03 night_opened", "2024-04-07 night_opened"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, event := range DiffAvailability(tt.previous, tt.current, observedAt) {
+				if event.Resort != "BLT" || event.RoomType != "4O" || !event.ObservedAt.Equal(observedAt) {
+					t.Fatalf("event not labelled with the request: %+v", event)
+				}
+				got = append(got, event.Date+" "+string(event.Kind))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeSnapshot(t *testing.T) {
+	observedAt := time.Date(2024, time.April, 2, 9, 0, 0, 0, time.UTC)
+	previous := AvailabilityResults{
+		ResortCode: "BLT",
+		RoomCode:   "4O",
+		Availability: []DayAvailability{
+			{Date: "2024-04-01", Rooms: 1},
+			{Date: "2024-04-03", Rooms: 1},
+			{Date: "2024-04-04", Rooms: 1},
+		},
+	}
+	current := AvailabilityResults{
+		ResortCode: "BLT",
+		RoomCode:   "4O",
+		Availability: []DayAvailability{
+			{Date: "2024-04-04", Rooms: 0},
+			{Date: "2024-04-05", Rooms: 2},
+		},
+	}
+
+	merged := mergeSnapshot(previous, current, observedAt)
+
+	want := []DayAvailability{
+		{Date: "2024-04-03", Rooms: 1},
+		{Date: "2024-04-04", Rooms: 0},
+		{Date: "2024-04-05", Rooms: 2},
+	}
+	if !reflect.DeepEqual(merged.Availability, want) {
+		t.Fatalf("got %+v, want %+v", merged.Availability, want)
+	}
+	if merged.ResortCode != "BLT" || merged.RoomCode != "4O" {
+		t.Fatalf("lost codes: %+v", merged)
+	}
+}